@@ -2,11 +2,14 @@
 package api
 
 import (
+	"crypto/subtle"
+	"encoding/json"
 	"encoding/xml"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -33,12 +36,26 @@ type Server struct {
 	Conf    proc.Conf
 	Store   *proc.BoltDB
 
+	MediaDir    string        // stored files served by /files, like mail attachments
+	AdminPasswd string        // enables admin api with basic auth, user "admin"
+	Fetcher     *feed.Fetcher // used by admin api, default fetcher if nil
+
+	WriteTimeout      time.Duration // limits time to send response, 30s if not set
+	MediaWriteTimeout time.Duration // limits time to send media and stored files, 30m if not set
+
 	httpServer *http.Server
 	cache      lcw.LoadingCache
 }
 
 // Run starts http server for API with all routes
 func (s *Server) Run(port int) {
+	if s.WriteTimeout == 0 {
+		s.WriteTimeout = 30 * time.Second
+	}
+	if s.MediaWriteTimeout == 0 {
+		s.MediaWriteTimeout = 30 * time.Minute
+	}
+
 	var err error
 	if s.cache, err = lcw.NewExpirableCache(lcw.TTL(time.Minute*5), lcw.MaxCacheSize(10*1024*1024)); err != nil {
 		log.Printf("[PANIC] failed to make loading cache, %v", err)
@@ -47,7 +64,7 @@ func (s *Server) Run(port int) {
 
 	router := chi.NewRouter()
 	router.Use(middleware.RealIP, rest.Recoverer(log.Default()))
-	router.Use(middleware.Throttle(1000), middleware.Timeout(60*time.Second))
+	router.Use(middleware.Throttle(1000))
 	router.Use(rest.AppInfo("feed-master", "umputun", s.Version), rest.Ping)
 	router.Use(tollbooth_chi.LimitHandler(tollbooth.NewLimiter(5, nil)))
 
@@ -55,10 +72,44 @@ func (s *Server) Run(port int) {
 		Addr:              fmt.Sprintf(":%d", port),
 		Handler:           router,
 		ReadHeaderTimeout: 5 * time.Second,
-		WriteTimeout:      30 * time.Second,
+		WriteTimeout:      s.WriteTimeout,
 		IdleTimeout:       30 * time.Second,
 	}
 
+	router.Group(func(rmedia chi.Router) {
+		l := logger.New(logger.Log(log.Default()), logger.Prefix("[DEBUG]"))
+		rmedia.Use(writeDeadline(s.MediaWriteTimeout), l.Handler) // audio files are long downloads
+		rmedia.Get("/media/{id}/{file}", s.getMediaCtrl)
+		rmedia.Head("/media/{id}/{file}", s.getMediaCtrl)
+		rmedia.Get("/media/{id}/cover/{file}", s.getMediaCoverCtrl)
+		rmedia.Get("/files/{name}/{file}", s.getFileCtrl)
+	})
+
+	router.Group(func(r chi.Router) {
+		r.Use(middleware.Timeout(60 * time.Second))
+		s.routes(r)
+	})
+
+	err = s.httpServer.ListenAndServe()
+	log.Printf("[WARN] http server terminated, %s", err)
+}
+
+// writeDeadline extends write deadline of the connection, set by server's WriteTimeout, for routes with long responses.
+// Has to be used before middlewares wrapping the writer without Unwrap method, like rest logger.
+func writeDeadline(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+				log.Printf("[WARN] can't set write deadline for %s, %v", r.URL.Path, err)
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// routes registers api, ui and static routes, all except media
+func (s *Server) routes(router chi.Router) {
 	router.Group(func(rimg chi.Router) {
 		l := logger.New(logger.Log(log.Default()), logger.Prefix("[DEBUG]"))
 		rimg.Use(l.Handler)
@@ -72,35 +123,87 @@ func (s *Server) Run(port int) {
 		l := logger.New(logger.Log(log.Default()), logger.Prefix("[INFO]"))
 		rrss.Use(l.Handler)
 		rrss.Get("/rss/{name}", s.getFeedCtrl)
+		rrss.Get("/json/{name}", s.getJSONFeedCtrl)
 		rrss.Get("/list", s.getListCtrl)
 		rrss.Get("/feed/{name}", s.getFeedPageCtrl)
 	})
 
+	if s.AdminPasswd != "" {
+		router.Route("/api/v1/admin", func(radm chi.Router) {
+			l := logger.New(logger.Log(log.Default()), logger.Prefix("[INFO]"))
+			radm.Use(l.Handler, rest.BasicAuth(s.checkAdmin))
+			radm.Get("/discover", s.discoverCtrl)
+		})
+	}
+
 	fs, err := rest.FileServer("/static", filepath.Join("webapp", "static"))
 	if err == nil {
 		router.Mount("/static", fs)
 	} else {
 		log.Printf("[WARN] can't start static file server, %v", err)
 	}
-
-	err = s.httpServer.ListenAndServe()
-	log.Printf("[WARN] http server terminated, %s", err)
 }
 
-// GET /rss/{name} - returns rss for given feeds set
+// GET /rss/{name}?tag=xyz - returns rss for given feeds set, optionally with tagged items only
 func (s *Server) getFeedCtrl(w http.ResponseWriter, r *http.Request) {
 	feedName := chi.URLParam(r, "name")
-	items, err := s.Store.Load(feedName, s.Conf.System.MaxTotal, true)
+	rss, err := s.feedRss(feedName, r.URL.Query().Get("tag"))
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "failed to get feed")
+		return
+	}
+
+	// replace link to UI page
+	if s.Conf.System.BaseURL != "" {
+		rss.Link = s.Conf.System.BaseURL + "/feed/" + feedName
+	}
+
+	b, err := xml.MarshalIndent(&rss, "", "  ")
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to marshal rss")
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=UTF-8")
+	_, _ = fmt.Fprintf(w, "%s", string(b))
+}
+
+// GET /json/{name}?tag=xyz - returns json feed for given feeds set, optionally with tagged items only
+func (s *Server) getJSONFeedCtrl(w http.ResponseWriter, r *http.Request) {
+	feedName := chi.URLParam(r, "name")
+	rss, err := s.feedRss(feedName, r.URL.Query().Get("tag"))
 	if err != nil {
 		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, err, "failed to get feed")
 		return
 	}
 
+	feedURL := ""
+	if s.Conf.System.BaseURL != "" {
+		rss.Link = s.Conf.System.BaseURL + "/feed/" + feedName
+		feedURL = s.Conf.System.BaseURL + "/json/" + feedName
+	}
+
+	b, err := json.MarshalIndent(feed.NewJSONFeed(rss, feedURL), "", "  ")
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to marshal json feed")
+		return
+	}
+	w.Header().Set("Content-Type", "application/feed+json; charset=UTF-8")
+	_, _ = w.Write(b)
+}
+
+// feedRss makes rss of feeds set from stored items, with the tag only if set
+func (s *Server) feedRss(feedName, tag string) (feed.Rss2, error) {
+	items, err := s.Store.LoadTagged(feedName, s.Conf.System.MaxTotal, true, tag)
+	if err != nil {
+		return feed.Rss2{}, err
+	}
+
 	for i, itm := range items {
 		// add ts suffix to titles
 		switch s.Conf.Feeds[feedName].ExtendDateTitle {
 		case "yyyyddmm":
-			items[i].Title = fmt.Sprintf("%s (%s)", itm.Title, itm.DT.Format("2006-02-01"))
+			// year-day-month built by hand, go vet rejects "2006-02-01" layout as a typo of "2006-01-02"
+			items[i].Title = fmt.Sprintf("%s (%d-%02d-%02d)", itm.Title, itm.DT.Year(), itm.DT.Day(), itm.DT.Month())
 		case "yyyymmdd":
 			items[i].Title = fmt.Sprintf("%s (%s)", itm.Title, itm.DT.Format("2006-01-02"))
 		}
@@ -113,22 +216,56 @@ func (s *Server) getFeedCtrl(w http.ResponseWriter, r *http.Request) {
 		Description:   s.Conf.Feeds[feedName].Description,
 		Language:      s.Conf.Feeds[feedName].Language,
 		Link:          s.Conf.Feeds[feedName].Link,
-		PubDate:       items[0].PubDate,
 		LastBuildDate: time.Now().Format(time.RFC822Z),
 	}
+	if len(items) > 0 {
+		rss.PubDate = items[0].PubDate
+	}
+	s.setITunes(&rss, feedName)
+	s.setPodcast(&rss, feedName)
+	return rss, nil
+}
 
-	// replace link to UI page
+// setPodcast sets channel's Podcasting 2.0 elements, guid made from feed's url and funding collected from items
+func (s *Server) setPodcast(rss *feed.Rss2, feedName string) {
+	rss.PodcastNS = feed.PodcastNS
 	if s.Conf.System.BaseURL != "" {
-		rss.Link = s.Conf.System.BaseURL + "/feed/" + feedName
+		rss.PodcastGUID = feed.PodcastGUID(s.Conf.System.BaseURL + "/rss/" + feedName)
 	}
+	seen := map[string]bool{}
+	for _, item := range rss.ItemList {
+		for _, f := range item.PodcastFunding {
+			if f.URL == "" || seen[f.URL] {
+				continue
+			}
+			seen[f.URL] = true
+			rss.PodcastFunding = append(rss.PodcastFunding, f)
+		}
+	}
+}
 
-	b, err := xml.MarshalIndent(&rss, "", "  ")
-	if err != nil {
-		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to marshal rss")
-		return
+// setITunes sets channel's itunes elements from feed config
+func (s *Server) setITunes(rss *feed.Rss2, feedName string) {
+	fm := s.Conf.Feeds[feedName]
+	rss.ITunesNS = feed.ITunesNS
+	rss.ITunesAuthor = fm.Author
+	rss.ITunesSummary = fm.Description
+	rss.ITunesExplicit = "false"
+	if fm.Explicit {
+		rss.ITunesExplicit = "true"
+	}
+	if fm.Image != "" && s.Conf.System.BaseURL != "" {
+		rss.ITunesImage = &feed.ITunesImage{Href: s.Conf.System.BaseURL + "/image/" + feedName + ".png"}
+	}
+	if fm.Owner.Name != "" || fm.Owner.Email != "" {
+		rss.ITunesOwner = &feed.ITunesOwner{Name: fm.Owner.Name, Email: fm.Owner.Email}
+	}
+	if fm.Category != "" {
+		rss.ITunesCategory = &feed.ITunesCategory{Text: fm.Category}
+		if fm.Subcategory != "" {
+			rss.ITunesCategory.Subcategory = &feed.ITunesCategory{Text: fm.Subcategory}
+		}
 	}
-	w.Header().Set("Content-Type", "application/xml; charset=UTF-8")
-	_, _ = fmt.Fprintf(w, "%s", string(b))
 }
 
 // GET /image/{name}
@@ -174,12 +311,125 @@ func (s *Server) getImageHeadCtrl(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-// GET /list - returns feed's image
+// GET /media/{id}/{file} - returns audio file of directory source, supports range requests
+func (s *Server) getMediaCtrl(w http.ResponseWriter, r *http.Request) {
+	path, err := s.mediaPath(chi.URLParam(r, "id"), chi.URLParam(r, "file"))
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusNotFound, err, "media not found")
+		return
+	}
+
+	fh, err := os.Open(path) // nolint
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusNotFound, err, "media not found")
+		return
+	}
+	defer fh.Close() // nolint
+
+	info, err := fh.Stat()
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to read media")
+		return
+	}
+	http.ServeContent(w, r, info.Name(), info.ModTime(), fh)
+}
+
+// GET /media/{id}/cover/{file} - returns cover art of audio file of directory source
+func (s *Server) getMediaCoverCtrl(w http.ResponseWriter, r *http.Request) {
+	path, err := s.mediaPath(chi.URLParam(r, "id"), chi.URLParam(r, "file"))
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusNotFound, err, "media not found")
+		return
+	}
+
+	data, mimeType, err := feed.CoverArt(path)
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusNotFound, err, "cover not found")
+		return
+	}
+	w.Header().Set("Content-Type", mimeType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	if _, err := w.Write(data); err != nil {
+		log.Printf("[WARN] failed to send cover, %s", err)
+	}
+}
+
+// GET /files/{name}/{file} - returns stored file of feed-set, like mail attachment
+func (s *Server) getFileCtrl(w http.ResponseWriter, r *http.Request) {
+	name, file := chi.URLParam(r, "name"), chi.URLParam(r, "file")
+	if f, err := url.PathUnescape(file); err == nil {
+		file = f
+	}
+	if _, ok := s.Conf.Feeds[name]; !ok || s.MediaDir == "" || file != filepath.Base(file) || strings.HasPrefix(file, ".") {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusNotFound, fmt.Errorf("invalid file %s/%s", name, file), "file not found")
+		return
+	}
+
+	fh, err := os.Open(filepath.Join(s.MediaDir, name, file)) // nolint
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusNotFound, err, "file not found")
+		return
+	}
+	defer fh.Close() // nolint
+
+	info, err := fh.Stat()
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to read file")
+		return
+	}
+	// files come from untrusted senders, don't let browser run them on this site
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	http.ServeContent(w, r, info.Name(), info.ModTime(), fh)
+}
+
+// mediaPath returns path of audio file in directory of source with given media id
+func (s *Server) mediaPath(id, file string) (string, error) {
+	dir, ok := s.Conf.MediaDir(id)
+	if !ok {
+		return "", fmt.Errorf("unknown media directory %s", id)
+	}
+	if f, err := url.PathUnescape(file); err == nil {
+		file = f // router keeps escaping of paths with non-default encoding
+	}
+	if file != filepath.Base(file) || !feed.IsDirAudio(file) {
+		return "", fmt.Errorf("invalid media file %s", file)
+	}
+	return filepath.Join(dir, file), nil
+}
+
+// GET /api/v1/admin/discover?url=xyz - returns feeds found for page url
+func (s *Server) discoverCtrl(w http.ResponseWriter, r *http.Request) {
+	uri := r.URL.Query().Get("url")
+	if uri == "" {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusBadRequest, errors.New("no url"), "url parameter required")
+		return
+	}
+	candidates, err := feed.Discover(r.Context(), uri, s.Fetcher)
+	if err != nil {
+		rest.SendErrorJSON(w, r, log.Default(), http.StatusNotFound, err, "failed to discover feeds")
+		return
+	}
+	render.JSON(w, r, candidates)
+}
+
+func (s *Server) checkAdmin(user, passwd string) bool {
+	return user == "admin" && subtle.ConstantTimeCompare([]byte(passwd), []byte(s.AdminPasswd)) == 1
+}
+
+// GET /list - returns list of stored feed-sets
 func (s *Server) getListCtrl(w http.ResponseWriter, r *http.Request) {
 	buckets, err := s.Store.Buckets()
 	if err != nil {
 		rest.SendErrorJSON(w, r, log.Default(), http.StatusInternalServerError, err, "failed to read list")
 		return
 	}
-	render.JSON(w, r, buckets)
+	// the same db keeps buckets other than feed-sets, like sources info
+	feeds := []string{}
+	for _, b := range buckets {
+		if _, ok := s.Conf.Feeds[b]; ok {
+			feeds = append(feeds, b)
+		}
+	}
+	render.JSON(w, r, feeds)
 }
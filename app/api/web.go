@@ -2,8 +2,11 @@ package api
 
 import (
 	"bytes"
+	"fmt"
 	"html/template"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -15,15 +18,27 @@ import (
 
 var templates = template.Must(template.ParseGlob("webapp/templates/*"))
 
-// GET /feed/{name} - renders page with list of items
+// GET /feed/{name}?tag=xyz - renders page with list of items, optionally with tagged items only
 func (s *Server) getFeedPageCtrl(w http.ResponseWriter, r *http.Request) {
 	feedName := chi.URLParam(r, "name")
+	tag := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("tag"))) // tags are case-insensitive
 
-	data, err := s.cache.Get(feedName, func() (interface{}, error) {
-		items, err := s.Store.Load(feedName, s.Conf.System.MaxTotal, false)
+	data, err := s.cache.Get(feedName+"?tag="+tag, func() (interface{}, error) {
+		all, err := s.Store.Load(feedName, s.Conf.System.MaxTotal, false)
 		if err != nil {
 			return nil, err
 		}
+		items := all
+		if tag != "" {
+			if items, err = s.Store.LoadTagged(feedName, s.Conf.System.MaxTotal, false, tag); err != nil {
+				return nil, err
+			}
+			if len(items) == 0 {
+				return nil, fmt.Errorf("no items for %s with tag %q", feedName, tag)
+			}
+		}
+
+		tags := itemsTags(all)
 		tmplData := struct {
 			Items       []feed.Item
 			Name        string
@@ -32,7 +47,11 @@ func (s *Server) getFeedPageCtrl(w http.ResponseWriter, r *http.Request) {
 			LastUpdate  time.Time
 			Feeds       int
 			Version     string
+			Tag         string
+			Tags        []string
 		}{
+			Tag:         activeTag(tags, tag),
+			Tags:        tags,
 			Items:       items,
 			Name:        s.Conf.Feeds[feedName].Title,
 			Description: s.Conf.Feeds[feedName].Description,
@@ -57,6 +76,33 @@ func (s *Server) getFeedPageCtrl(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write(data.([]byte)) // nolint
 }
 
+// itemsTags returns sorted unique tags of items, case-insensitive
+func itemsTags(items []feed.Item) []string {
+	res := []string{}
+	seen := map[string]bool{}
+	for _, item := range items {
+		for _, t := range item.Tags {
+			if seen[strings.ToLower(t)] {
+				continue
+			}
+			seen[strings.ToLower(t)] = true
+			res = append(res, t)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// activeTag returns tag as shown in tags list, to be matched by the template
+func activeTag(tags []string, tag string) string {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return t
+		}
+	}
+	return tag
+}
+
 func (s *Server) renderErrorPage(w http.ResponseWriter, r *http.Request, err error, errCode int) {
 	tmplData := struct {
 		Status int
@@ -0,0 +1,182 @@
+package feed
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"html/template"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Atom1 is atom feed
+type Atom1 struct {
+	XMLName   xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+	Base      string   `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+	Title     string   `xml:"title"`
+	Subtitle  string   `xml:"subtitle"`
+	ID        string   `xml:"id"`
+	Updated   string   `xml:"updated"`
+	Rights    string   `xml:"rights"`
+	Links     []Link   `xml:"link"`
+	Authors   []Author `xml:"author"`
+	EntryList []Entry  `xml:"entry"`
+}
+
+// Link element for xml
+type Link struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr"`
+	Type   string `xml:"type,attr"`
+	Length int    `xml:"length,attr"`
+	Title  string `xml:"title,attr"`
+}
+
+// Author element for xml
+type Author struct {
+	Name  string `xml:"name"`
+	Email string `xml:"email"`
+}
+
+// Category element for xml, label is human-readable form of term
+type Category struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr"`
+}
+
+// Text is atom text construct, content is plain text, escaped html or inline xhtml depending on type
+type Text struct {
+	Type     string `xml:"type,attr"`
+	Base     string `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+	Body     string `xml:",chardata"`
+	InnerXML string `xml:",innerxml"`
+}
+
+// Entry from atom
+type Entry struct {
+	Base       string     `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+	Title      string     `xml:"http://www.w3.org/2005/Atom title"`
+	Summary    Text       `xml:"http://www.w3.org/2005/Atom summary"`
+	Content    Text       `xml:"http://www.w3.org/2005/Atom content"` // namespaced, not to be mixed with media:content
+	ID         string     `xml:"id"`
+	Published  string     `xml:"published"`
+	Updated    string     `xml:"updated"`
+	Links      []Link     `xml:"link"`
+	Authors    []Author   `xml:"author"`
+	Categories []Category `xml:"http://www.w3.org/2005/Atom category"`
+	MediaElements
+}
+
+func parseAtom(content []byte) (Rss2, error) {
+	a := Atom1{}
+	err := xml.Unmarshal(content, &a)
+	if err != nil {
+		return Rss2{}, errors.Wrap(err, "can't parse atom1")
+	}
+	return atom1ToRss2(a), nil
+}
+
+func atom1ToRss2(a Atom1) Rss2 {
+	r := Rss2{
+		Title:       a.Title,
+		Link:        resolveURL(a.Base, alternateLink(a.Links).Href),
+		Description: a.Subtitle,
+		PubDate:     pubDate(a.Updated),
+	}
+	r.ItemList = make([]Item, len(a.EntryList))
+	for i, entry := range a.EntryList {
+		base := a.Base
+		if entry.Base != "" {
+			base = resolveURL(a.Base, entry.Base)
+		}
+		item := Item{
+			Title: strings.TrimSpace(entry.Title),
+			Link:  resolveURL(base, alternateLink(entry.Links).Href),
+			GUID:  entry.ID,
+		}
+
+		// published preferred as it is not changed on entry edit
+		item.PubDate = pubDate(entry.Published, entry.Updated)
+
+		item.Description = entry.Summary.html()
+		if content := entry.Content.html(); content != "" {
+			item.Description = content
+		}
+
+		authors := entry.Authors
+		if len(authors) == 0 {
+			authors = a.Authors
+		}
+		item.Author = atomAuthors(authors)
+
+		for _, l := range entry.Links {
+			if l.Rel == "enclosure" {
+				item.Enclosure = Enclosure{URL: resolveURL(base, l.Href), Type: l.Type, Length: l.Length}
+				break
+			}
+		}
+		entry.MediaElements.apply(&item, base)
+
+		for _, c := range entry.Categories {
+			if c.Label != "" {
+				item.Tags = append(item.Tags, c.Label)
+				continue
+			}
+			item.Tags = append(item.Tags, c.Term)
+		}
+		r.ItemList[i] = item
+	}
+	return r
+}
+
+// html returns html representation of atom text construct
+func (t Text) html() template.HTML {
+	switch t.Type {
+	case "html":
+		return template.HTML(strings.TrimSpace(t.Body)) // nolint
+	case "xhtml":
+		return template.HTML(strings.TrimSpace(xhtmlDivContent(t.InnerXML))) // nolint
+	default: // text
+		return template.HTML(html.EscapeString(strings.TrimSpace(t.Body))) // nolint
+	}
+}
+
+// xhtmlDivContent strips wrapping xhtml div required by atom for inline xhtml content
+func xhtmlDivContent(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "<div") || !strings.HasSuffix(s, "</div>") {
+		return s
+	}
+	start := strings.Index(s, ">")
+	if start < 0 {
+		return s
+	}
+	return s[start+1 : len(s)-len("</div>")]
+}
+
+// alternateLink returns link with rel="alternate" or without rel, as it means alternate by default
+func alternateLink(links []Link) Link {
+	for _, l := range links {
+		if l.Rel == "" || l.Rel == "alternate" {
+			return l
+		}
+	}
+	return Link{}
+}
+
+func atomAuthors(authors []Author) string {
+	res := make([]string, 0, len(authors))
+	for _, a := range authors {
+		name, email := strings.TrimSpace(a.Name), strings.TrimSpace(a.Email)
+		switch {
+		case email != "" && name != "":
+			res = append(res, fmt.Sprintf("%s (%s)", email, name))
+		case email != "":
+			res = append(res, email)
+		case name != "":
+			res = append(res, name)
+		}
+	}
+	return strings.Join(res, ", ")
+}
@@ -0,0 +1,97 @@
+package feed
+
+import (
+	"html/template"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAtomFull(t *testing.T) {
+	atom1 := `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xml:base="http://example.org/">
+  <title>Example Podcast</title>
+  <subtitle>All about examples</subtitle>
+  <link rel="self" type="application/atom+xml" href="http://example.org/feed.atom"/>
+  <link rel="alternate" type="text/html" href="/podcast/"/>
+  <updated>2021-07-10T18:30:02Z</updated>
+  <author><name>John Doe</name><email>john@example.org</email></author>
+  <id>urn:uuid:60a76c80-d399-11d9-b93C-0003939e0af6</id>
+
+  <entry xml:base="/episodes/">
+    <title type="text">Episode 1</title>
+    <link rel="alternate" type="text/html" href="ep1.html"/>
+    <link rel="enclosure" type="audio/mpeg" length="1337" href="ep1.mp3"/>
+    <id>urn:uuid:1225c695-cfb8-4ebb-aaaa-80da344efa6a</id>
+    <published>2021-07-09T10:00:00+03:00</published>
+    <updated>2021-07-10T18:30:02Z</updated>
+    <summary type="html">&lt;p&gt;Some &lt;b&gt;html&lt;/b&gt;&lt;/p&gt;</summary>
+  </entry>
+
+  <entry>
+    <title>Episode 2</title>
+    <link href="http://other.example.com/ep2"/>
+    <id>tag:example.org,2021:ep2</id>
+    <updated>2021-07-10T18:30:02.123Z</updated>
+    <author><name>Jane Roe</name></author>
+    <summary>Plain &lt;text&gt;</summary>
+    <content type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml"><p>Inline <em>xhtml</em></p></div></content>
+  </entry>
+</feed>`
+
+	got, err := parseAtom([]byte(atom1))
+	require.NoError(t, err)
+
+	assert.Equal(t, "Example Podcast", got.Title)
+	assert.Equal(t, "All about examples", got.Description)
+	assert.Equal(t, "http://example.org/podcast/", got.Link, "alternate link resolved against xml:base")
+	assert.Equal(t, "Sat, 10 Jul 2021 18:30:02 +0000", got.PubDate)
+	require.Len(t, got.ItemList, 2)
+
+	item := got.ItemList[0]
+	assert.Equal(t, "Episode 1", item.Title)
+	assert.Equal(t, "urn:uuid:1225c695-cfb8-4ebb-aaaa-80da344efa6a", item.GUID)
+	assert.Equal(t, "http://example.org/episodes/ep1.html", item.Link)
+	assert.Equal(t, Enclosure{URL: "http://example.org/episodes/ep1.mp3", Type: "audio/mpeg", Length: 1337}, item.Enclosure)
+	assert.Equal(t, "Fri, 09 Jul 2021 10:00:00 +0300", item.PubDate, "published preferred over updated")
+	assert.Equal(t, template.HTML("<p>Some <b>html</b></p>"), item.Description)
+	assert.Equal(t, "john@example.org (John Doe)", item.Author, "feed author inherited")
+
+	item = got.ItemList[1]
+	assert.Equal(t, "tag:example.org,2021:ep2", item.GUID)
+	assert.Equal(t, "http://other.example.com/ep2", item.Link)
+	assert.Equal(t, "Sat, 10 Jul 2021 18:30:02 +0000", item.PubDate, "updated with fractions")
+	assert.Equal(t, template.HTML("<p>Inline <em>xhtml</em></p>"), item.Description, "content preferred over summary")
+	assert.Equal(t, "Jane Roe", item.Author)
+	assert.Equal(t, Enclosure{}, item.Enclosure)
+
+	norm, err := got.Normalize()
+	require.NoError(t, err)
+	assert.Equal(t, 2021, norm.ItemList[0].DT.Year())
+}
+
+func TestAtomTextHTML(t *testing.T) {
+	tbl := []struct {
+		text Text
+		res  template.HTML
+	}{
+		{Text{Body: " plain <text> "}, "plain &lt;text&gt;"},
+		{Text{Type: "text", Body: "a & b"}, "a &amp; b"},
+		{Text{Type: "html", Body: "<p>html</p>"}, "<p>html</p>"},
+		{Text{Type: "xhtml", InnerXML: `<div xmlns="http://www.w3.org/1999/xhtml"><p>x</p></div>`}, "<p>x</p>"},
+		{Text{Type: "xhtml", InnerXML: `<p>no div</p>`}, "<p>no div</p>"},
+	}
+	for _, tt := range tbl {
+		assert.Equal(t, tt.res, tt.text.html())
+	}
+}
+
+func TestAtomCategories(t *testing.T) {
+	atom := `<feed xmlns="http://www.w3.org/2005/Atom"><title>Feed</title>
+		<entry><id>1</id><title>Entry</title><category term="tech"/><category term="go" label="Golang"/></entry></feed>`
+	got, err := parseFeedContent([]byte(atom))
+	require.NoError(t, err)
+	require.Len(t, got.ItemList, 1)
+	assert.Equal(t, []string{"tech", "Golang"}, got.ItemList[0].Tags)
+}
@@ -0,0 +1,133 @@
+package feed
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// dateLayouts are tried in order by parseDate, after the date string is normalized to english short
+// month names, without weekday and with numeric zone
+var dateLayouts = []string{
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04 -0700",
+	"2 Jan 06 15:04:05 -0700",
+	"2 Jan 06 15:04 -0700",
+	"2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04 MST",
+	"2 Jan 06 15:04:05 MST",
+	"2 Jan 06 15:04 MST",
+	"2 Jan 2006 15:04:05",
+	"2 Jan 2006 15:04",
+	"2 Jan 2006",
+	"Jan 2 2006 15:04:05 -0700",
+	"Jan 2 2006 15:04:05",
+	"Jan 2 2006",
+	time.RFC3339, // fractions accepted as well
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"02.01.2006 15:04:05",
+	"02.01.2006 15:04",
+	"02.01.2006",
+}
+
+// zoneOffsets maps zone abbreviations seen in feeds to numeric offsets, as time.Parse makes zero offset
+// for abbreviations unknown to the local time zone
+var zoneOffsets = map[string]string{
+	"UT": "+0000", "UTC": "+0000", "GMT": "+0000", "Z": "+0000", "WET": "+0000",
+	"BST": "+0100", "CET": "+0100", "WEST": "+0100",
+	"CEST": "+0200", "EET": "+0200", "SAST": "+0200",
+	"EEST": "+0300", "MSK": "+0300", "MSD": "+0400",
+	"EST": "-0500", "EDT": "-0400", "CST": "-0600", "CDT": "-0500",
+	"MST": "-0700", "MDT": "-0600", "PST": "-0800", "PDT": "-0700",
+	"AKST": "-0900", "AKDT": "-0800", "HST": "-1000",
+}
+
+// monthNames maps russian and full english month names, in all used forms, to short english names
+var monthNames = map[string]string{
+	"январь": "Jan", "января": "Jan", "янв": "Jan", "january": "Jan",
+	"февраль": "Feb", "февраля": "Feb", "фев": "Feb", "февр": "Feb", "february": "Feb",
+	"март": "Mar", "марта": "Mar", "мар": "Mar", "march": "Mar",
+	"апрель": "Apr", "апреля": "Apr", "апр": "Apr", "april": "Apr",
+	"май": "May", "мая": "May",
+	"июнь": "Jun", "июня": "Jun", "июн": "Jun", "june": "Jun",
+	"июль": "Jul", "июля": "Jul", "июл": "Jul", "july": "Jul",
+	"август": "Aug", "августа": "Aug", "авг": "Aug", "august": "Aug",
+	"сентябрь": "Sep", "сентября": "Sep", "сен": "Sep", "сент": "Sep", "september": "Sep", "sept": "Sep",
+	"октябрь": "Oct", "октября": "Oct", "окт": "Oct", "october": "Oct",
+	"ноябрь": "Nov", "ноября": "Nov", "ноя": "Nov", "нояб": "Nov", "november": "Nov",
+	"декабрь": "Dec", "декабря": "Dec", "дек": "Dec", "december": "Dec",
+}
+
+var (
+	weekdayPrefixRe = regexp.MustCompile(`^\p{L}+\.?,\s*`)
+	colonZoneRe     = regexp.MustCompile(`\s([+-]\d{2}):(\d{2})$`)
+	zoneAbbrRe      = regexp.MustCompile(`\s([A-Z]{1,5})$`)
+)
+
+// parseDate parses dates in formats seen in feeds: RFC822/RFC1123 variants with single digit days, missing seconds
+// and named zones, RFC3339 with fractions, ISO-like and russian dates like "10 июля 2021 18:30"
+func parseDate(dt string) (time.Time, error) {
+	s := normalizeDateString(dt)
+	if s == "" {
+		return time.Time{}, errors.New("empty date")
+	}
+	for _, layout := range dateLayouts {
+		if ts, err := time.Parse(layout, s); err == nil {
+			return ts, nil
+		}
+	}
+	return time.Time{}, errors.Errorf("unknown date format %q", dt)
+}
+
+func normalizeDateString(dt string) string {
+	s := strings.Join(strings.Fields(dt), " ")
+	s = weekdayPrefixRe.ReplaceAllString(s, "")
+
+	fields := strings.Fields(s)
+	res := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if f == "г." || f == "г" { // russian "year" suffix
+			continue
+		}
+		if m, ok := monthNames[strings.ToLower(strings.TrimSuffix(f, "."))]; ok {
+			f = m
+		}
+		if strings.HasSuffix(f, ",") { // "Jan 2, 2006"
+			f = strings.TrimSuffix(f, ",")
+		}
+		res = append(res, f)
+	}
+	s = strings.Join(res, " ")
+
+	s = colonZoneRe.ReplaceAllString(s, " $1$2")
+	if m := zoneAbbrRe.FindStringSubmatch(s); m != nil {
+		if offset, ok := zoneOffsets[m[1]]; ok {
+			s = strings.TrimSuffix(s, m[1]) + offset
+		}
+	}
+	return s
+}
+
+// pubDate returns the first parsable of given dates formatted as RFC1123Z, used by rss.
+// Returns the first non-empty date as is if none parsable.
+func pubDate(dates ...string) string {
+	for _, dt := range dates {
+		if ts, err := parseDate(dt); err == nil {
+			return ts.Format(time.RFC1123Z)
+		}
+	}
+	for _, dt := range dates {
+		if dt = strings.TrimSpace(dt); dt != "" {
+			return dt
+		}
+	}
+	return ""
+}
@@ -0,0 +1,98 @@
+package feed
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDate(t *testing.T) {
+	tbl := []struct {
+		inp, out string
+	}{
+		{"Mon, 02 Jan 2006 15:04:05 -0700", "2006-01-02T15:04:05-07:00"},
+		{"Mon, 2 Jan 2006 15:04:05 -0700", "2006-01-02T15:04:05-07:00"},
+		{"2 Jan 2006 15:04:05 +0300", "2006-01-02T15:04:05+03:00"},
+		{"Mon, 02 Jan 2006 15:04 -0700", "2006-01-02T15:04:00-07:00"},
+		{"Sat,  10 Jul 2021 18:31:09 EST", "2021-07-10T18:31:09-05:00"},
+		{"Sat, 10 Jul 2021 18:31:09 MSK", "2021-07-10T18:31:09+03:00"},
+		{"Sat, 10 Jul 2021 18:31 EEST", "2021-07-10T18:31:00+03:00"},
+		{"Sat, 10 Jul 2021 18:31:09 GMT", "2021-07-10T18:31:09Z"},
+		{"Sat, 10 Jul 2021 18:31:09 +03:00", "2021-07-10T18:31:09+03:00"},
+		{"10 Jul 21 18:31 +0300", "2021-07-10T18:31:00+03:00"},
+		{"Saturday, 10 July 2021 18:31:09 +0300", "2021-07-10T18:31:09+03:00"},
+		{"July 10, 2021", "2021-07-10T00:00:00Z"},
+		{"2021-07-10T18:31:09.123456+03:00", "2021-07-10T18:31:09.123456+03:00"},
+		{"2021-07-10T18:31:09Z", "2021-07-10T18:31:09Z"},
+		{"2021-07-10T18:31+03:00", "2021-07-10T18:31:00+03:00"},
+		{"2021-07-10 18:31:09", "2021-07-10T18:31:09Z"},
+		{"2021-07-10", "2021-07-10T00:00:00Z"},
+		{"10.07.2021 18:31", "2021-07-10T18:31:00Z"},
+		{"10 июля 2021 18:31", "2021-07-10T18:31:00Z"},
+		{"10 июля 2021 г.", "2021-07-10T00:00:00Z"},
+		{"Сб, 10 июл 2021 18:31:09 +0300", "2021-07-10T18:31:09+03:00"},
+		{"1 марта 2021 09:05:00 MSK", "2021-03-01T09:05:00+03:00"},
+		{"5 сент. 2021 10:00", "2021-09-05T10:00:00Z"},
+	}
+
+	for _, tt := range tbl {
+		ts, err := parseDate(tt.inp)
+		require.NoError(t, err, tt.inp)
+		assert.Equal(t, tt.out, ts.Format(time.RFC3339Nano), tt.inp)
+	}
+
+	for _, inp := range []string{"", "  ", "100500", "yesterday", "32 Jan 2021"} {
+		_, err := parseDate(inp)
+		assert.Error(t, err, inp)
+	}
+}
+
+func TestPubDate(t *testing.T) {
+	assert.Equal(t, "Sat, 10 Jul 2021 18:30:02 +0300", pubDate("2021-07-10T18:30:02+03:00"))
+	assert.Equal(t, "Sat, 10 Jul 2021 00:00:00 +0000", pubDate("bad", " 2021-07-10 "), "first parsable")
+	assert.Equal(t, "bad", pubDate("", "bad", "worse"), "first non-empty if none parsable")
+	assert.Equal(t, "", pubDate("", " "))
+}
+
+func TestNormalizeDateFallback(t *testing.T) {
+	rss := Rss2{
+		PubDate: "Sat, 10 Jul 2021 18:30:02 +0300",
+		ItemList: []Item{
+			{GUID: "1", PubDate: "10 июля 2021 12:00 MSK"},
+			{GUID: "2", PubDate: "bad date"},
+			{GUID: "3"},
+		},
+	}
+	got, err := rss.Normalize()
+	require.NoError(t, err)
+	assert.Equal(t, "Sat, 10 Jul 2021 12:00:00 +0300", got.ItemList[0].PubDate)
+	assert.Equal(t, "Sat, 10 Jul 2021 18:30:02 +0300", got.ItemList[1].PubDate, "channel date")
+	assert.Equal(t, "Sat, 10 Jul 2021 18:30:02 +0300", got.ItemList[2].PubDate, "channel date")
+	assert.False(t, got.ItemList[0].DateFallback)
+	assert.True(t, got.ItemList[1].DateFallback)
+	assert.True(t, got.ItemList[2].DateFallback)
+
+	rss = Rss2{LastBuildDate: "Sun, 11 Jul 2021 10:00:00 +0300", ItemList: []Item{{GUID: "1"}}}
+	got, err = rss.Normalize()
+	require.NoError(t, err)
+	assert.Equal(t, "Sun, 11 Jul 2021 10:00:00 +0300", got.PubDate)
+	assert.True(t, got.ItemList[0].DT.IsZero(), "lastBuildDate not used for items")
+	assert.False(t, got.ItemList[0].DateFallback)
+
+	rss = Rss2{ItemList: []Item{{GUID: "1", PubDate: "bad date"}}}
+	got, err = rss.Normalize()
+	require.NoError(t, err)
+	assert.True(t, got.ItemList[0].DT.IsZero(), "no date, left for the store")
+	assert.Equal(t, "bad date", got.ItemList[0].PubDate)
+}
+
+func TestParseAtomUpdatedFallback(t *testing.T) {
+	atom1 := `<feed xmlns="http://www.w3.org/2005/Atom"><title>t</title>
+  <entry><id>1</id><published>garbage</published><updated>2021-07-10T18:30:02Z</updated></entry>
+</feed>`
+	got, err := parseAtom([]byte(atom1))
+	require.NoError(t, err)
+	assert.Equal(t, "Sat, 10 Jul 2021 18:30:02 +0000", got.ItemList[0].PubDate)
+}
@@ -0,0 +1,292 @@
+package feed
+
+import (
+	"encoding/binary"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dhowden/tag"
+	log "github.com/go-pkgz/lgr"
+	"github.com/pkg/errors"
+	"github.com/tcolgate/mp3"
+)
+
+// Dir makes feed of audio files in directory, mp3 and m4a. Title, date, author, description, duration and cover art
+// taken from ID3 or MP4 tags, with fallback to file name and modification time. Items of files read before
+// are kept till the file changes, so only new and modified files are read on each Parse.
+type Dir struct {
+	Path    string
+	BaseURL string // url the directory served at, enclosure is BaseURL/{file} and cover is BaseURL/cover/{file}
+
+	mu    sync.Mutex
+	files map[string]dirFile
+}
+
+// dirFile is item made from the file, with file's size and modification time to detect changes
+type dirFile struct {
+	size    int64
+	modTime time.Time
+	item    Item
+}
+
+// dirAudioExts are extensions of files picked by Dir
+var dirAudioExts = map[string]bool{".mp3": true, ".m4a": true}
+
+// IsDirAudio checks if file name is picked by Dir
+func IsDirAudio(name string) bool {
+	return !strings.HasPrefix(name, ".") && dirAudioExts[strings.ToLower(filepath.Ext(name))]
+}
+
+// Parse reads the directory and returns normalized Rss2 with an item per audio file, the newest first
+func (d *Dir) Parse() (Rss2, error) {
+	infos, err := ioutil.ReadDir(d.Path)
+	if err != nil {
+		return Rss2{}, errors.Wrapf(err, "can't read directory %s", d.Path)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	files := map[string]dirFile{}
+	result := Rss2{Version: "2.0", Title: filepath.Base(d.Path), Link: d.BaseURL, FeedURL: d.BaseURL}
+	for _, fi := range infos {
+		if fi.IsDir() || !IsDirAudio(fi.Name()) {
+			continue
+		}
+		f, ok := d.files[fi.Name()]
+		if !ok || f.size != fi.Size() || !f.modTime.Equal(fi.ModTime()) {
+			f = dirFile{size: fi.Size(), modTime: fi.ModTime(), item: d.fileItem(fi)}
+		}
+		files[fi.Name()] = f
+		result.ItemList = append(result.ItemList, f.item)
+	}
+	d.files = files
+
+	result, err = result.Normalize()
+	if err != nil {
+		return Rss2{}, err
+	}
+	sort.SliceStable(result.ItemList, func(i, j int) bool { return result.ItemList[i].DT.After(result.ItemList[j].DT) })
+	return result, nil
+}
+
+// fileItem makes item of the audio file, tags are optional
+func (d *Dir) fileItem(fi os.FileInfo) Item {
+	name := fi.Name()
+	fileURL := d.BaseURL + "/" + url.PathEscape(name)
+	item := Item{
+		Title:     strings.TrimSuffix(name, filepath.Ext(name)),
+		GUID:      fileURL,
+		Enclosure: Enclosure{URL: fileURL, Length: int(fi.Size()), Type: audioTypes[strings.ToLower(filepath.Ext(name))]},
+	}
+	dt := fi.ModTime()
+
+	fh, err := os.Open(filepath.Join(d.Path, name)) // nolint
+	if err != nil {
+		log.Printf("[WARN] can't open %s, %v", name, err)
+		item.PubDate = dt.UTC().Format(time.RFC3339)
+		return item
+	}
+	defer fh.Close() // nolint
+
+	if m, err := tag.ReadFrom(fh); err == nil {
+		if t := strings.TrimSpace(m.Title()); t != "" {
+			item.Title = t
+		}
+		item.Author = strings.TrimSpace(m.Artist())
+		item.ITunesAuthor = item.Author
+		if c := strings.TrimSpace(m.Comment()); c != "" {
+			item.Description = template.HTML(html.EscapeString(c)) // nolint
+		}
+		if tagDT, ok := tagDate(m); ok {
+			dt = tagDT
+		}
+		if p := m.Picture(); p != nil && len(p.Data) > 0 {
+			item.Thumbnail = d.BaseURL + "/cover/" + url.PathEscape(name)
+			item.ITunesImage = &ITunesImage{Href: item.Thumbnail}
+		}
+	} else if err != tag.ErrNoTagsFound {
+		log.Printf("[DEBUG] can't read tags of %s, %v", name, err)
+	}
+
+	if _, err := fh.Seek(0, io.SeekStart); err == nil {
+		if dur := audioDuration(fh, filepath.Ext(name)); dur > 0 {
+			item.ITunesDuration = strconv.Itoa(int(dur.Seconds()))
+		}
+	}
+	item.PubDate = dt.UTC().Format(time.RFC3339)
+	return item
+}
+
+// tagDate returns recording date of ID3 (TDRC, or TYER with TDAT) or MP4 (©day) tags, year only dates ignored
+func tagDate(m tag.Metadata) (time.Time, bool) {
+	raw := m.Raw()
+	str := func(key string) string {
+		s, _ := raw[key].(string)
+		return strings.TrimSpace(s)
+	}
+
+	dt := str("TDRC")
+	if dt == "" {
+		dt = str("\xa9day")
+	}
+	if y, d := str("TYER"), str("TDAT"); dt == "" && len(y) == 4 && len(d) == 4 {
+		dt = fmt.Sprintf("%s-%s-%s", y, d[2:], d[:2]) // TDAT is DDMM
+	}
+	if len(dt) <= 4 {
+		return time.Time{}, false
+	}
+	res, err := parseDate(dt)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return res, true
+}
+
+// CoverArt returns picture from tags of the audio file and its mime type
+func CoverArt(path string) (data []byte, mimeType string, err error) {
+	fh, err := os.Open(path) // nolint
+	if err != nil {
+		return nil, "", err
+	}
+	defer fh.Close() // nolint
+
+	m, err := tag.ReadFrom(fh)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "can't read tags of %s", path)
+	}
+	p := m.Picture()
+	if p == nil || len(p.Data) == 0 {
+		return nil, "", errors.Errorf("no cover art in %s", path)
+	}
+	mimeType = p.MIMEType
+	if mimeType == "" || !strings.Contains(mimeType, "/") {
+		mimeType = "image/jpeg"
+	}
+	return p.Data, mimeType, nil
+}
+
+// audioDuration returns duration of mp3 or m4a file, zero if can't detect
+func audioDuration(r io.ReadSeeker, ext string) time.Duration {
+	if strings.EqualFold(ext, ".m4a") {
+		d, err := mp4Duration(r)
+		if err != nil {
+			log.Printf("[DEBUG] can't get mp4 duration, %v", err)
+		}
+		return d
+	}
+
+	d := mp3.NewDecoder(r)
+	var f mp3.Frame
+	var skipped int
+	var res time.Duration
+	for {
+		if err := d.Decode(&f, &skipped); err != nil {
+			if err != io.EOF {
+				log.Printf("[DEBUG] can't get mp3 duration, %v", err)
+				return 0
+			}
+			return res
+		}
+		res += f.Duration()
+	}
+}
+
+// mp4Duration returns duration from movie header (moov/mvhd) of mp4 file
+func mp4Duration(r io.ReadSeeker) (time.Duration, error) {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	for pos := int64(0); pos < end; {
+		name, hdrSize, size, err := mp4AtomHeader(r, end-pos)
+		if err != nil {
+			return 0, err
+		}
+		switch name {
+		case "moov":
+			end = pos + size // look into children of moov only
+			pos += hdrSize
+			continue
+		case "mvhd":
+			return mvhdDuration(r)
+		}
+		pos += size
+		if _, err = r.Seek(pos, io.SeekStart); err != nil {
+			return 0, err
+		}
+	}
+	return 0, errors.New("no mvhd atom")
+}
+
+// mp4AtomHeader reads atom name, header size and full size, 64-bit and to-the-end sizes included
+func mp4AtomHeader(r io.Reader, left int64) (name string, hdrSize, size int64, err error) {
+	var hdr [8]byte
+	if _, err = io.ReadFull(r, hdr[:]); err != nil {
+		return "", 0, 0, err
+	}
+	name, hdrSize, size = string(hdr[4:]), 8, int64(binary.BigEndian.Uint32(hdr[:4]))
+	switch size {
+	case 0:
+		size = left
+	case 1:
+		var ext [8]byte
+		if _, err = io.ReadFull(r, ext[:]); err != nil {
+			return "", 0, 0, err
+		}
+		hdrSize, size = 16, int64(binary.BigEndian.Uint64(ext[:]))
+	}
+	if size < hdrSize || size > left {
+		return "", 0, 0, errors.Errorf("invalid size %d of atom %q", size, name)
+	}
+	return name, hdrSize, size, nil
+}
+
+// mvhdDuration reads timescale and duration of mvhd atom, reader positioned after atom's header
+func mvhdDuration(r io.Reader) (time.Duration, error) {
+	var ver [4]byte // version and flags
+	if _, err := io.ReadFull(r, ver[:]); err != nil {
+		return 0, err
+	}
+
+	var timescale uint32
+	var duration uint64
+	if ver[0] == 1 {
+		var v struct {
+			Created, Modified uint64
+			Timescale         uint32
+			Duration          uint64
+		}
+		if err := binary.Read(r, binary.BigEndian, &v); err != nil {
+			return 0, err
+		}
+		timescale, duration = v.Timescale, v.Duration
+	} else {
+		var v struct {
+			Created, Modified, Timescale, Duration uint32
+		}
+		if err := binary.Read(r, binary.BigEndian, &v); err != nil {
+			return 0, err
+		}
+		timescale, duration = v.Timescale, uint64(v.Duration)
+	}
+
+	if timescale == 0 {
+		return 0, errors.New("zero timescale")
+	}
+	return time.Duration(float64(duration) / float64(timescale) * float64(time.Second)), nil
+}
@@ -0,0 +1,92 @@
+package feed
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirParse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fm-dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	copyFile(t, "testdata/episode1.mp3", filepath.Join(dir, "episode1.mp3"))
+	copyFile(t, "testdata/episode2.m4a", filepath.Join(dir, "episode 2.m4a"))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("notes"), 0o600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, ".hidden.mp3"), []byte("x"), 0o600))
+
+	d := Dir{Path: dir, BaseURL: "https://fm.example.com/media/abc"}
+	rss, err := d.Parse()
+	require.NoError(t, err)
+	require.Len(t, rss.ItemList, 2)
+
+	m4a := rss.ItemList[0]
+	assert.Equal(t, "Episode 2: Выпуск", m4a.Title)
+	assert.Equal(t, "Host Name", m4a.Author)
+	assert.Equal(t, "Notes &lt;b&gt;2&lt;/b&gt;", string(m4a.Description))
+	assert.Equal(t, time.Date(2021, 7, 12, 18, 30, 0, 0, time.UTC), m4a.DT.UTC())
+	assert.Equal(t, "12", m4a.ITunesDuration)
+	assert.Equal(t, Enclosure{URL: "https://fm.example.com/media/abc/episode%202.m4a", Length: 532, Type: "audio/mp4"}, m4a.Enclosure)
+	assert.Equal(t, "https://fm.example.com/media/abc/cover/episode%202.m4a", m4a.Thumbnail)
+	require.NotNil(t, m4a.ITunesImage)
+	assert.Equal(t, m4a.Thumbnail, m4a.ITunesImage.Href)
+
+	mp3 := rss.ItemList[1]
+	assert.Equal(t, "Episode 1: Intro", mp3.Title)
+	assert.Equal(t, "Show notes &amp; links", string(mp3.Description))
+	assert.Equal(t, time.Date(2021, 7, 10, 0, 0, 0, 0, time.UTC), mp3.DT.UTC())
+	assert.Equal(t, "3", mp3.ITunesDuration)
+	assert.Equal(t, "https://fm.example.com/media/abc/episode1.mp3", mp3.Enclosure.URL)
+	assert.Equal(t, "audio/mpeg", mp3.Enclosure.Type)
+	assert.Equal(t, mp3.Enclosure.URL, mp3.GUID)
+
+	// new file without tags, named by file and dated by modification time
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "raw.mp3"), []byte("not an mp3"), 0o600))
+	modTime := time.Date(2021, 8, 1, 10, 0, 0, 0, time.UTC)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "raw.mp3"), modTime, modTime))
+	rss, err = d.Parse()
+	require.NoError(t, err)
+	require.Len(t, rss.ItemList, 3)
+	assert.Equal(t, "raw", rss.ItemList[0].Title)
+	assert.Equal(t, modTime, rss.ItemList[0].DT.UTC())
+	assert.Equal(t, "", rss.ItemList[0].ITunesDuration)
+	assert.Nil(t, rss.ItemList[0].ITunesImage)
+
+	_, err = (&Dir{Path: filepath.Join(dir, "missing")}).Parse()
+	assert.Error(t, err)
+}
+
+func TestCoverArt(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n")
+
+	data, mimeType, err := CoverArt("testdata/episode1.mp3")
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", mimeType)
+	assert.Equal(t, png, data[:8])
+
+	data, mimeType, err = CoverArt("testdata/episode2.m4a")
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", mimeType)
+	assert.Equal(t, png, data[:8])
+
+	_, _, err = CoverArt("testdata/episodes.html")
+	assert.Error(t, err)
+}
+
+func TestIsDirAudio(t *testing.T) {
+	assert.True(t, IsDirAudio("ep1.mp3"))
+	assert.True(t, IsDirAudio("Ep 2.M4A"))
+	assert.False(t, IsDirAudio(".ep1.mp3"))
+	assert.False(t, IsDirAudio("cover.jpg"))
+}
+
+func copyFile(t *testing.T, src, dst string) {
+	data, err := ioutil.ReadFile(src)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(dst, data, 0o600))
+}
@@ -0,0 +1,188 @@
+package feed
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"strings"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/pkg/errors"
+	"golang.org/x/net/html"
+)
+
+// Candidate is a feed found by Discover
+type Candidate struct {
+	URL   string `json:"url"`
+	Title string `json:"title"`
+	Type  string `json:"type,omitempty"`
+}
+
+// feedTypes are types of alternate links pointing to feeds
+var feedTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/rdf+xml":   true,
+	"application/feed+json": true,
+	"application/json":      true,
+	"text/xml":              true,
+	"application/xml":       true,
+}
+
+// commonFeedPaths are probed if html page has no alternate links
+var commonFeedPaths = []string{"/feed", "/rss", "/feed.xml", "/rss.xml", "/atom.xml", "/index.xml", "/podcast.xml", "/feed.json"}
+
+// ErrHTMLPage returned by parser for html content, Discover can find feeds of such page
+var ErrHTMLPage = errors.New("html page, not a feed")
+
+// Discover finds feeds of the url. Returns it as the only candidate if url points to a feed. For html page returns
+// feeds from its alternate links, or ones found at common paths of the site.
+func Discover(ctx context.Context, uri string, fetcher *Fetcher) ([]Candidate, error) {
+	if fetcher == nil {
+		fetcher = defaultFetcher
+	}
+
+	resp, err := fetcher.Fetch(ctx, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	contentType := resp.Header.Get("Content-Type")
+
+	rss, err := parseBody(resp.Body, contentType, uri)
+	if err == nil {
+		return []Candidate{{URL: uri, Title: rss.Title, Type: mediaType(contentType)}}, nil
+	}
+	if !errors.Is(err, ErrHTMLPage) {
+		return nil, errors.Wrapf(err, "%s is neither feed nor html page", uri)
+	}
+
+	res := alternateFeeds(resp.Body, uri)
+	if len(res) > 0 {
+		return res, nil
+	}
+
+	for _, p := range commonFeedPaths {
+		feedURL := resolveURL(uri, p)
+		resp, err := fetcher.Fetch(ctx, feedURL, nil)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue
+		}
+		rss, err := parseBody(resp.Body, resp.Header.Get("Content-Type"), feedURL)
+		if err != nil {
+			log.Printf("[DEBUG] no feed at %s, %v", feedURL, err)
+			continue
+		}
+		res = append(res, Candidate{URL: feedURL, Title: rss.Title, Type: mediaType(resp.Header.Get("Content-Type"))})
+	}
+
+	if len(res) == 0 {
+		return nil, errors.Errorf("no feeds found at %s", uri)
+	}
+	return res, nil
+}
+
+// alternateFeeds returns feeds linked by <link rel="alternate"> of html page, page's title used for links without title
+func alternateFeeds(page []byte, pageURL string) []Candidate {
+	res := []Candidate{}
+	base, title := pageURL, ""
+	inTitle := false
+
+	z := html.NewTokenizer(bytes.NewReader(page))
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if z.Err() != io.EOF {
+				log.Printf("[DEBUG] can't parse html of %s, %v", pageURL, z.Err())
+			}
+			for i := range res {
+				if res[i].Title == "" {
+					res[i].Title = title
+				}
+			}
+			return res
+		case html.TextToken:
+			if inTitle && title == "" {
+				title = strings.TrimSpace(html.UnescapeString(string(z.Text())))
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "title" {
+				inTitle = false
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			switch tok.Data {
+			case "title":
+				inTitle = tt == html.StartTagToken
+			case "base":
+				if href := attr(tok.Attr, "href"); href != "" {
+					base = resolveURL(pageURL, href)
+				}
+			case "link":
+				if !hasWord(attr(tok.Attr, "rel"), "alternate") || !feedTypes[strings.ToLower(attr(tok.Attr, "type"))] {
+					continue
+				}
+				if href := attr(tok.Attr, "href"); href != "" {
+					res = append(res, Candidate{URL: resolveURL(base, href), Title: attr(tok.Attr, "title"), Type: attr(tok.Attr, "type")})
+				}
+			}
+		}
+	}
+}
+
+// isHTML checks if content starts as html document, html is not always valid xml to check its root element
+func isHTML(content []byte) bool {
+	head := bytes.TrimPrefix(content, utf8BOM)
+	if len(head) > 4096 {
+		head = head[:4096]
+	}
+	head = bytes.ToLower(bytes.TrimSpace(head))
+
+	for {
+		var end []byte
+		switch {
+		case bytes.HasPrefix(head, []byte("<?")): // xml declaration
+			end = []byte("?>")
+		case bytes.HasPrefix(head, []byte("<!--")):
+			end = []byte("-->")
+		default:
+			return bytes.HasPrefix(head, []byte("<!doctype html")) || bytes.HasPrefix(head, []byte("<html"))
+		}
+		i := bytes.Index(head, end)
+		if i < 0 {
+			return false
+		}
+		head = bytes.TrimSpace(head[i+len(end):])
+	}
+}
+
+func attr(attrs []html.Attribute, key string) string {
+	for _, a := range attrs {
+		if a.Key == key {
+			return strings.TrimSpace(a.Val)
+		}
+	}
+	return ""
+}
+
+// hasWord checks if space separated list has the word, case-insensitive
+func hasWord(list, word string) bool {
+	for _, w := range strings.Fields(list) {
+		if strings.EqualFold(w, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func mediaType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return mt
+}
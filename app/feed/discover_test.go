@@ -0,0 +1,99 @@
+package feed
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscover(t *testing.T) {
+	rss := `<rss version="2.0"><channel><title>Podcast feed</title></channel></rss>`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write([]byte(`<!DOCTYPE html>
+<html><head><title>Podcast &amp; Co</title>
+<meta charset=utf-8>
+<link rel="stylesheet" href="/style.css">
+<link rel="alternate" type="application/rss+xml" title="Episodes" href="/podcast.rss">
+<link rel="alternate" type="application/atom+xml" href="https://other.example.com/atom.xml">
+<link rel="alternate" hreflang="ru" href="/ru/">
+</head><body></body></html>`))
+		case "/blog/post":
+			_, _ = w.Write([]byte(`<html><head><base href="/blog/"><link rel="Alternate Feed" type="application/feed+json" href="feed.json" title="JSON"></head></html>`))
+		case "/nolinks":
+			_, _ = w.Write([]byte(`<html><head><title>No links</title></head></html>`))
+		case "/feed", "/podcast.rss":
+			w.Header().Set("Content-Type", "application/rss+xml")
+			_, _ = w.Write([]byte(rss))
+		case "/text":
+			_, _ = w.Write([]byte("plain text"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	t.Run("alternate links", func(t *testing.T) {
+		res, err := Discover(context.Background(), ts.URL+"/page", nil)
+		require.NoError(t, err)
+		assert.Equal(t, []Candidate{
+			{URL: ts.URL + "/podcast.rss", Title: "Episodes", Type: "application/rss+xml"},
+			{URL: "https://other.example.com/atom.xml", Title: "Podcast & Co", Type: "application/atom+xml"},
+		}, res)
+	})
+
+	t.Run("base href", func(t *testing.T) {
+		res, err := Discover(context.Background(), ts.URL+"/blog/post", nil)
+		require.NoError(t, err)
+		assert.Equal(t, []Candidate{{URL: ts.URL + "/blog/feed.json", Title: "JSON", Type: "application/feed+json"}}, res)
+	})
+
+	t.Run("common paths", func(t *testing.T) {
+		res, err := Discover(context.Background(), ts.URL+"/nolinks", nil)
+		require.NoError(t, err)
+		assert.Equal(t, []Candidate{{URL: ts.URL + "/feed", Title: "Podcast feed", Type: "application/rss+xml"}}, res)
+	})
+
+	t.Run("feed url", func(t *testing.T) {
+		res, err := Discover(context.Background(), ts.URL+"/podcast.rss", nil)
+		require.NoError(t, err)
+		assert.Equal(t, []Candidate{{URL: ts.URL + "/podcast.rss", Title: "Podcast feed", Type: "application/rss+xml"}}, res)
+	})
+
+	t.Run("not html", func(t *testing.T) {
+		_, err := Discover(context.Background(), ts.URL+"/text", nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("parse html page", func(t *testing.T) {
+		_, err := Parse(ts.URL + "/page")
+		assert.ErrorIs(t, err, ErrHTMLPage)
+	})
+}
+
+func TestIsHTML(t *testing.T) {
+	tbl := []struct {
+		inp string
+		res bool
+	}{
+		{"<!DOCTYPE html><html></html>", true},
+		{"\xef\xbb\xbf  <HTML lang=en>", true},
+		{`<?xml version="1.0"?><!-- comment --><!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Strict//EN">`, true},
+		{`<?xml version="1.0"?><rss version="2.0"></rss>`, false},
+		{"<!-- unclosed", false},
+		{`{"version": "https://jsonfeed.org/version/1.1"}`, false},
+	}
+	for i, tt := range tbl {
+		tt := tt
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			assert.Equal(t, tt.res, isHTML([]byte(tt.inp)))
+		})
+	}
+}
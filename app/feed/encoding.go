@@ -0,0 +1,84 @@
+package feed
+
+import (
+	"bytes"
+	"mime"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+var (
+	xmlDeclEncodingRe = regexp.MustCompile(`^(\s*<\?xml[^>]*?encoding\s*=\s*)["']([A-Za-z0-9._:\-]+)["']`)
+	utf8BOM           = []byte("\xef\xbb\xbf")
+	utf16LEBOM        = []byte{0xff, 0xfe}
+	utf16BEBOM        = []byte{0xfe, 0xff}
+)
+
+// toUTF8 transcodes feed content to UTF-8. Encoding detected by BOM, charset of http Content-Type
+// or encoding of xml declaration, in this order. Declared xml encoding replaced by UTF-8 after transcoding.
+func toUTF8(content []byte, contentType string) ([]byte, error) {
+	var enc encoding.Encoding
+	switch {
+	case bytes.HasPrefix(content, utf8BOM):
+		content = bytes.TrimPrefix(content, utf8BOM)
+	case bytes.HasPrefix(content, utf16LEBOM):
+		enc = unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM)
+	case bytes.HasPrefix(content, utf16BEBOM):
+		enc = unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM)
+	default:
+		label := contentTypeCharset(contentType)
+		if label == "" {
+			label = xmlDeclEncoding(content)
+		}
+		if label != "" {
+			e, name := charset.Lookup(label)
+			if e == nil {
+				return nil, errors.Errorf("unsupported encoding %q", label)
+			}
+			if name != "utf-8" {
+				enc = e
+			}
+		}
+	}
+
+	if enc != nil {
+		res, _, err := transform.Bytes(enc.NewDecoder(), content)
+		if err != nil {
+			return nil, errors.Wrap(err, "can't transcode to utf-8")
+		}
+		content = res
+	}
+
+	return xmlDeclEncodingRe.ReplaceAll(content, []byte(`${1}"UTF-8"`)), nil
+}
+
+// contentTypeCharset returns charset parameter of http Content-Type header
+func contentTypeCharset(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(params["charset"])
+}
+
+// xmlDeclEncoding returns encoding declared in xml declaration
+func xmlDeclEncoding(content []byte) string {
+	head := content
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+	m := xmlDeclEncodingRe.FindSubmatch(head)
+	if m == nil {
+		return ""
+	}
+	return string(m[2])
+}
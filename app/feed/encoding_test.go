@@ -0,0 +1,92 @@
+package feed
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const cyrillicFeed = `<?xml version="1.0" encoding="%s"?>
+<rss version="2.0">
+  <channel>
+    <title>Эхо Москвы</title>
+    <item><title>Особое мнение</title><guid>1</guid></item>
+  </channel>
+</rss>`
+
+func TestToUTF8(t *testing.T) {
+	win1251, err := charmap.Windows1251.NewEncoder().String(`<?xml version="1.0" encoding="windows-1251"?><rss>Привет</rss>`)
+	require.NoError(t, err)
+	koi8r, err := charmap.KOI8R.NewEncoder().String(`<?xml version='1.0' encoding='KOI8-R'?><rss>Привет</rss>`)
+	require.NoError(t, err)
+	noDecl, err := charmap.Windows1251.NewEncoder().String(`<rss>Привет</rss>`)
+	require.NoError(t, err)
+	utf16, err := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().String(`<?xml version="1.0" encoding="UTF-16"?><rss>Привет</rss>`)
+	require.NoError(t, err)
+
+	tbl := []struct {
+		inp, contentType, out, err string
+	}{
+		{`<?xml version="1.0" encoding="UTF-8"?><rss>Привет</rss>`, "", `<?xml version="1.0" encoding="UTF-8"?><rss>Привет</rss>`, ""},
+		{"\xef\xbb\xbf<rss>Привет</rss>", "", `<rss>Привет</rss>`, ""},
+		{win1251, "", `<?xml version="1.0" encoding="UTF-8"?><rss>Привет</rss>`, ""},
+		{koi8r, "text/xml", `<?xml version='1.0' encoding="UTF-8"?><rss>Привет</rss>`, ""},
+		{noDecl, "application/rss+xml; charset=windows-1251", `<rss>Привет</rss>`, ""},
+		{win1251, "text/xml; charset=cp1251", `<?xml version="1.0" encoding="UTF-8"?><rss>Привет</rss>`, ""},
+		{utf16, "", `<?xml version="1.0" encoding="UTF-8"?><rss>Привет</rss>`, ""},
+		{`<?xml version="1.0" encoding="no-such"?><rss/>`, "", "", `unsupported encoding "no-such"`},
+	}
+
+	for i, tt := range tbl {
+		tt := tt
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			res, err := toUTF8([]byte(tt.inp), tt.contentType)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.out, string(res))
+		})
+	}
+}
+
+func TestFeedParseWindows1251(t *testing.T) {
+	body, err := charmap.Windows1251.NewEncoder().String(fmt.Sprintf(cyrillicFeed, "windows-1251"))
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+		_, _ = w.Write([]byte(body))
+	}))
+	defer ts.Close()
+
+	r, err := Parse(ts.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "Эхо Москвы", r.Title)
+	require.Len(t, r.ItemList, 1)
+	assert.Equal(t, "Особое мнение", r.ItemList[0].Title)
+}
+
+func TestFeedParseContentTypeCharset(t *testing.T) {
+	// header charset takes precedence over (wrong) xml declaration
+	body, err := charmap.KOI8R.NewEncoder().String(fmt.Sprintf(cyrillicFeed, "UTF-8"))
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml; charset=koi8-r")
+		_, _ = w.Write([]byte(body))
+	}))
+	defer ts.Close()
+
+	r, err := Parse(ts.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "Эхо Москвы", r.Title)
+}
@@ -0,0 +1,111 @@
+package feed
+
+import (
+	"encoding/xml"
+	"sort"
+	"strings"
+)
+
+// Extension is raw element of the source item not mapped to Item fields, like source or dc:creator.
+// Namespaces used by the element declared on the element itself, to be emitted as is in any feed.
+type Extension struct {
+	XMLName  xml.Name   // namespace url and local name
+	Prefix   string     `xml:"-"` // prefix of the namespace in the source
+	Attrs    []xml.Attr `xml:",any,attr"`
+	InnerXML string     `xml:",innerxml"`
+}
+
+// MarshalXML emits extension with the source prefix
+func (e Extension) MarshalXML(enc *xml.Encoder, _ xml.StartElement) error {
+	name := xml.Name{Local: e.XMLName.Local}
+	if e.Prefix != "" {
+		name.Local = e.Prefix + ":" + e.XMLName.Local
+	}
+
+	attrs := make([]xml.Attr, 0, len(e.Attrs))
+	for _, a := range e.Attrs {
+		switch a.Name.Space {
+		case "xmlns":
+			a.Name = xml.Name{Local: "xmlns:" + a.Name.Local}
+		case "http://www.w3.org/XML/1998/namespace":
+			a.Name = xml.Name{Local: "xml:" + a.Name.Local}
+		}
+		attrs = append(attrs, a)
+	}
+
+	v := struct {
+		Attrs    []xml.Attr `xml:",any,attr"`
+		InnerXML string     `xml:",innerxml"`
+	}{Attrs: attrs, InnerXML: e.InnerXML}
+	return enc.EncodeElement(v, xml.StartElement{Name: name})
+}
+
+// declaredNamespaces returns namespaces declared by attributes of the element, as url to prefix and prefix to url
+func declaredNamespaces(attrs []xml.Attr) (prefixes, namespaces map[string]string) {
+	prefixes, namespaces = map[string]string{}, map[string]string{}
+	for _, a := range attrs {
+		if a.Name.Space == "xmlns" {
+			prefixes[a.Value] = a.Name.Local
+			namespaces[a.Name.Local] = a.Value
+		}
+	}
+	return prefixes, namespaces
+}
+
+// withNamespaces sets prefix of the extension and declares namespaces it uses, if declared by the source's root
+// or by the element itself. Element with namespace declared elsewhere (like channel) gets it as default namespace.
+func (e Extension) withNamespaces(prefixes, namespaces map[string]string) Extension {
+	declared := map[string]bool{}
+	local := map[string]string{} // url to prefix, root's ones overridden by element's own declarations
+	for url, p := range prefixes {
+		local[url] = p
+	}
+	for _, a := range e.Attrs {
+		if a.Name.Space == "xmlns" {
+			declared[a.Name.Local] = true
+			local[a.Value] = a.Name.Local
+		}
+		if a.Name.Space == "" && a.Name.Local == "xmlns" {
+			declared[""] = true
+		}
+	}
+	prefixes = local
+
+	var used []string
+	attrs := []xml.Attr{}
+	switch p, ok := prefixes[e.XMLName.Space]; {
+	case e.XMLName.Space == "" || declared[""]: // no namespace or declared by element itself
+	case ok:
+		e.Prefix = p
+		used = append(used, p)
+	default:
+		attrs = append(attrs, xml.Attr{Name: xml.Name{Local: "xmlns"}, Value: e.XMLName.Space})
+	}
+
+	// prefixed attributes of known namespaces kept with their prefix
+	for i, a := range e.Attrs {
+		p, ok := prefixes[a.Name.Space]
+		if !ok || a.Name.Space == "xmlns" || a.Name.Space == "" {
+			continue
+		}
+		e.Attrs[i].Name = xml.Name{Local: p + ":" + a.Name.Local}
+		used = append(used, p)
+	}
+
+	for p := range namespaces {
+		if strings.Contains(e.InnerXML, p+":") {
+			used = append(used, p)
+		}
+	}
+	sort.Strings(used)
+
+	for _, p := range used {
+		if declared[p] {
+			continue
+		}
+		declared[p] = true
+		attrs = append(attrs, xml.Attr{Name: xml.Name{Space: "xmlns", Local: p}, Value: namespaces[p]})
+	}
+	e.Attrs = append(attrs, e.Attrs...)
+	return e
+}
@@ -0,0 +1,83 @@
+package feed
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExtensions(t *testing.T) {
+	rss := `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:cc="http://web.resource.org/cc/"
+	xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd">
+<channel xmlns:ch="urn:channel">
+	<title>Feed</title>
+	<item>
+		<title>Item 1</title>
+		<guid>1</guid>
+		<category domain="https://example.com/tags">News &amp; Politics</category>
+		<source url="https://example.com/rss">Example</source>
+		<dc:creator>Someone</dc:creator>
+		<cc:license rdf:resource="http://creativecommons.org/licenses/by/4.0/"/>
+		<cc:work><dc:title>Nested</dc:title></cc:work>
+		<x:custom xmlns:x="urn:x" x:attr="v">value</x:custom>
+		<ch:field>channel ns</ch:field>
+		<itunes:duration>3600</itunes:duration>
+	</item>
+</channel>
+</rss>`
+
+	got, err := parseFeedContent([]byte(rss))
+	require.NoError(t, err)
+	require.Len(t, got.ItemList, 1)
+	item := got.ItemList[0]
+	assert.Equal(t, "3600", item.ITunesDuration)
+	assert.Equal(t, []string{"News & Politics"}, item.Tags)
+	require.Len(t, item.Extensions, 6, "all but mapped to fields")
+
+	names := []string{}
+	for _, ext := range item.Extensions {
+		names = append(names, ext.XMLName.Space+" "+ext.XMLName.Local)
+	}
+	assert.Equal(t, []string{" source", "http://purl.org/dc/elements/1.1/ creator",
+		"http://web.resource.org/cc/ license", "http://web.resource.org/cc/ work", "urn:x custom", "urn:channel field"}, names)
+
+	// survives store's json and emitted as is
+	data, err := json.Marshal(item)
+	require.NoError(t, err)
+	stored := Item{}
+	require.NoError(t, json.Unmarshal(data, &stored))
+
+	b, err := xml.Marshal(stored.Extensions)
+	require.NoError(t, err)
+	assert.Equal(t, `<source url="https://example.com/rss">Example</source>`+
+		`<dc:creator xmlns:dc="http://purl.org/dc/elements/1.1/">Someone</dc:creator>`+
+		`<cc:license xmlns:cc="http://web.resource.org/cc/" xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" `+
+		`rdf:resource="http://creativecommons.org/licenses/by/4.0/"></cc:license>`+
+		`<cc:work xmlns:cc="http://web.resource.org/cc/" xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>Nested</dc:title></cc:work>`+
+		`<x:custom xmlns:x="urn:x" x:attr="v">value</x:custom>`+
+		`<field xmlns="urn:channel">channel ns</field>`, string(b))
+}
+
+func TestParseExtensionsRoundTrip(t *testing.T) {
+	rss := `<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/"><channel><title>Feed</title>
+		<item><title>Item 1</title><guid>1</guid><dc:creator>Someone</dc:creator><source url="https://example.com/rss">src</source></item>
+		</channel></rss>`
+
+	got, err := parseFeedContent([]byte(rss))
+	require.NoError(t, err)
+	b, err := xml.Marshal(&got)
+	require.NoError(t, err)
+
+	back, err := parseFeedContent(b)
+	require.NoError(t, err)
+	require.Len(t, back.ItemList, 1)
+	assert.Equal(t, "Item 1", back.ItemList[0].Title)
+	require.Len(t, back.ItemList[0].Extensions, 2)
+	assert.Equal(t, "http://purl.org/dc/elements/1.1/", back.ItemList[0].Extensions[0].XMLName.Space)
+	assert.Equal(t, "Someone", back.ItemList[0].Extensions[0].InnerXML)
+	assert.Equal(t, "src", back.ItemList[0].Extensions[1].InnerXML)
+}
@@ -0,0 +1,203 @@
+package feed
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/pkg/errors"
+)
+
+// FetchOpts defines parameters of http fetching
+type FetchOpts struct {
+	Timeout     time.Duration // timeout of a single request, including reading of the body
+	UserAgent   string
+	MaxBodySize int64         // max size of (uncompressed) body, 0 for no limit
+	Retries     int           // number of retries on network errors and 5xx/429 responses
+	RetryDelay  time.Duration // initial delay between retries, doubled on each retry
+	Proxy       string        // proxy url, proxy from environment used if empty
+}
+
+// Fetcher makes http requests for feeds and their media, shared by all sources
+type Fetcher struct {
+	FetchOpts
+	transport *http.Transport
+}
+
+// Response is a result of Fetcher.Fetch
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// DefaultUserAgent used if FetchOpts.UserAgent not set
+const DefaultUserAgent = "feed-master (+https://github.com/umputun/feed-master)"
+
+var defaultFetcher, _ = NewFetcher(FetchOpts{}) // no error possible without proxy
+
+// DefaultFetcher returns fetcher with default options, used by functions called without fetcher
+func DefaultFetcher() *Fetcher {
+	return defaultFetcher
+}
+
+// NewFetcher makes Fetcher, fills unset options with defaults
+func NewFetcher(opts FetchOpts) (*Fetcher, error) {
+	if opts.Timeout == 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = DefaultUserAgent
+	}
+	if opts.RetryDelay == 0 {
+		opts.RetryDelay = time.Second
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.Proxy != "" {
+		proxyURL, err := url.Parse(opts.Proxy)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid proxy %s", opts.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	return &Fetcher{FetchOpts: opts, transport: transport}, nil
+}
+
+// WithTimeout returns copy of fetcher, sharing its transport, with different request timeout
+func (f *Fetcher) WithTimeout(timeout time.Duration) *Fetcher {
+	res := *f
+	res.Timeout = timeout
+	return &res
+}
+
+// Fetch makes GET request with optional extra headers and reads the body, up to MaxBodySize.
+// Failed requests retried with exponential backoff. Responses with status other than 2xx and 304 returned as errors.
+func (f *Fetcher) Fetch(ctx context.Context, uri string, header http.Header) (*Response, error) {
+	var resp *Response
+	var err error
+	delay := f.RetryDelay
+	for attempt := 0; attempt <= f.Retries; attempt++ {
+		if attempt > 0 {
+			log.Printf("[DEBUG] retry #%d of %s in %v, %v", attempt, uri, delay, err)
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(delay):
+			}
+			delay *= 2
+		}
+
+		var retry bool
+		resp, retry, err = f.fetch(ctx, uri, header)
+		if err == nil || !retry {
+			return resp, err
+		}
+	}
+	return nil, err
+}
+
+func (f *Fetcher) fetch(ctx context.Context, uri string, header http.Header) (resp *Response, retry bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, f.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	for k, vals := range header {
+		for _, v := range vals {
+			req.Header.Add(k, v)
+		}
+	}
+	req.Header.Set("User-Agent", f.UserAgent)
+
+	httpResp, err := f.client(0).Do(req)
+	if err != nil {
+		return nil, ctx.Err() == nil || errors.Is(ctx.Err(), context.DeadlineExceeded), err
+	}
+	defer func() {
+		if e := httpResp.Body.Close(); e != nil {
+			log.Printf("[WARN] failed to close body, %s", e)
+		}
+	}()
+
+	if httpResp.StatusCode != http.StatusNotModified && (httpResp.StatusCode < 200 || httpResp.StatusCode >= 300) {
+		retry = httpResp.StatusCode >= 500 || httpResp.StatusCode == http.StatusTooManyRequests
+		return nil, retry, errors.Errorf("%s returned status %d", uri, httpResp.StatusCode)
+	}
+
+	body, err := f.readBody(httpResp.Body)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "can't read body of %s", uri)
+	}
+	return &Response{StatusCode: httpResp.StatusCode, Header: httpResp.Header, Body: body}, false, nil
+}
+
+// readBody reads up to MaxBodySize, gunzips body compressed without Content-Encoding (like served .gz files)
+func (f *Fetcher) readBody(r io.Reader) ([]byte, error) {
+	body, err := f.limitedRead(r)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(body, []byte{0x1f, 0x8b}) {
+		return body, nil
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close() // nolint
+	return f.limitedRead(gz)
+}
+
+func (f *Fetcher) limitedRead(r io.Reader) ([]byte, error) {
+	if f.MaxBodySize <= 0 {
+		return ioutil.ReadAll(r)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r, f.MaxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > f.MaxBodySize {
+		return nil, fmt.Errorf("body exceeds %d bytes", f.MaxBodySize)
+	}
+	return body, nil
+}
+
+// Stream makes GET request and returns the body without reading it, for large media files.
+// Timeout covers the whole request including reading of the body, fetcher's timeout used if zero.
+// Body size is not limited, caller reads it as a stream.
+func (f *Fetcher) Stream(ctx context.Context, uri string, timeout time.Duration) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.UserAgent)
+
+	if timeout == 0 {
+		timeout = f.Timeout
+	}
+	resp, err := f.client(timeout).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		_ = resp.Body.Close()
+		return nil, errors.Errorf("%s returned status %d", uri, resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// client makes http client with shared transport, timeout 0 means no client timeout (context controlled)
+func (f *Fetcher) client(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: f.transport, Timeout: timeout}
+}
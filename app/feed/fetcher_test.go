@@ -0,0 +1,265 @@
+package feed
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetcherFetch(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "test-agent", r.Header.Get("User-Agent"))
+		assert.Equal(t, "val", r.Header.Get("X-Test"))
+		w.Header().Set("Content-Type", "text/xml")
+		_, _ = w.Write([]byte("body"))
+	}))
+	defer ts.Close()
+
+	f, err := NewFetcher(FetchOpts{UserAgent: "test-agent"})
+	require.NoError(t, err)
+	resp, err := f.Fetch(context.Background(), ts.URL, http.Header{"X-Test": []string{"val"}})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/xml", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "body", string(resp.Body))
+}
+
+func TestFetcherRetries(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("body"))
+	}))
+	defer ts.Close()
+
+	f, err := NewFetcher(FetchOpts{Retries: 1, RetryDelay: time.Millisecond})
+	require.NoError(t, err)
+	_, err = f.Fetch(context.Background(), ts.URL, nil)
+	assert.EqualError(t, err, ts.URL+" returned status 503")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	atomic.StoreInt32(&calls, 0)
+	f, err = NewFetcher(FetchOpts{Retries: 2, RetryDelay: time.Millisecond})
+	require.NoError(t, err)
+	resp, err := f.Fetch(context.Background(), ts.URL, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "body", string(resp.Body))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestFetcherNoRetryOnClientError(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	f, err := NewFetcher(FetchOpts{Retries: 3, RetryDelay: time.Millisecond})
+	require.NoError(t, err)
+	_, err = f.Fetch(context.Background(), ts.URL, nil)
+	assert.EqualError(t, err, ts.URL+" returned status 404")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestFetcherTimeoutAndCancel(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer ts.Close()
+
+	f, err := NewFetcher(FetchOpts{Timeout: 50 * time.Millisecond})
+	require.NoError(t, err)
+	st := time.Now()
+	_, err = f.Fetch(context.Background(), ts.URL, nil)
+	assert.Error(t, err)
+	assert.True(t, time.Since(st) < 500*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = f.WithTimeout(time.Minute).Fetch(ctx, ts.URL, nil)
+	assert.Error(t, err)
+	assert.Equal(t, 50*time.Millisecond, f.Timeout, "original fetcher not changed")
+}
+
+func TestFetcherMaxBodySize(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("x", 100)))
+	}))
+	defer ts.Close()
+
+	f, err := NewFetcher(FetchOpts{MaxBodySize: 99})
+	require.NoError(t, err)
+	_, err = f.Fetch(context.Background(), ts.URL, nil)
+	assert.EqualError(t, err, "can't read body of "+ts.URL+": body exceeds 99 bytes")
+
+	f, err = NewFetcher(FetchOpts{MaxBodySize: 100})
+	require.NoError(t, err)
+	resp, err := f.Fetch(context.Background(), ts.URL, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 100, len(resp.Body))
+}
+
+func TestFetcherGzip(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write([]byte("compressed body"))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/encoded" {
+			assert.Contains(t, r.Header.Get("Accept-Encoding"), "gzip")
+			w.Header().Set("Content-Encoding", "gzip")
+		}
+		_, _ = w.Write(buf.Bytes())
+	}))
+	defer ts.Close()
+
+	f, err := NewFetcher(FetchOpts{})
+	require.NoError(t, err)
+	for _, path := range []string{"/encoded", "/feed.xml.gz"} {
+		resp, err := f.Fetch(context.Background(), ts.URL+path, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "compressed body", string(resp.Body), path)
+	}
+}
+
+func TestFetcherProxy(t *testing.T) {
+	var proxied int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&proxied, 1)
+		assert.Equal(t, "http://feed.example.com/rss", r.URL.String())
+		_, _ = w.Write([]byte("via proxy"))
+	}))
+	defer proxy.Close()
+
+	f, err := NewFetcher(FetchOpts{Proxy: proxy.URL})
+	require.NoError(t, err)
+	resp, err := f.Fetch(context.Background(), "http://feed.example.com/rss", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "via proxy", string(resp.Body))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&proxied))
+
+	_, err = NewFetcher(FetchOpts{Proxy: "://bad"})
+	assert.Error(t, err)
+}
+
+func TestFetcherStream(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "test-agent", r.Header.Get("User-Agent"))
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("audio"))
+	}))
+	defer ts.Close()
+
+	f, err := NewFetcher(FetchOpts{UserAgent: "test-agent"})
+	require.NoError(t, err)
+	body, err := f.Stream(context.Background(), ts.URL+"/file.mp3", 0)
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(body)
+	require.NoError(t, err)
+	assert.Equal(t, "audio", string(data))
+	require.NoError(t, body.Close())
+
+	_, err = f.Stream(context.Background(), ts.URL+"/missing", 0)
+	assert.EqualError(t, err, ts.URL+"/missing returned status 404")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = f.Stream(ctx, ts.URL+"/file.mp3", 0)
+	assert.True(t, errors.Is(err, context.Canceled), err)
+}
+
+func TestParseReader(t *testing.T) {
+	rss := `<?xml version="1.0" encoding="UTF-8"?><rss version="2.0"><channel><title>Feed</title>
+	<item><title> Item 1 </title><guid>1</guid><pubDate>Sat, 10 Jul 2021 18:31:09 +0300</pubDate></item></channel></rss>`
+	got, err := ParseReader(strings.NewReader(rss), "")
+	require.NoError(t, err)
+	assert.Equal(t, "Feed", got.Title)
+	require.Len(t, got.ItemList, 1)
+	assert.Equal(t, "Item 1", got.ItemList[0].Title, "normalized")
+	assert.Equal(t, 2021, got.ItemList[0].DT.Year())
+
+	_, err = ParseReader(strings.NewReader("bad"), "")
+	assert.Error(t, err)
+}
+
+func TestParseWithOptions(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "custom", r.Header.Get("User-Agent"))
+		_, _ = w.Write([]byte(`<rss version="2.0"><channel><title>Feed</title></channel></rss>`))
+	}))
+	defer ts.Close()
+
+	f, err := NewFetcher(FetchOpts{UserAgent: "custom"})
+	require.NoError(t, err)
+	got, err := ParseWithOptions(context.Background(), ts.URL, ParseOpts{Fetcher: f, Timeout: time.Second})
+	require.NoError(t, err)
+	assert.Equal(t, "Feed", got.Title)
+}
+
+func TestParseWithValidators(t *testing.T) {
+	body := `<rss version="2.0"><channel><title>Feed</title></channel></rss>`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/etag" {
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Last-Modified", "Sat, 10 Jul 2021 18:31:09 GMT")
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer ts.Close()
+
+	t.Run("etag", func(t *testing.T) {
+		v := &Validators{}
+		got, err := ParseWithOptions(context.Background(), ts.URL+"/etag", ParseOpts{Validators: v})
+		require.NoError(t, err)
+		assert.Equal(t, "Feed", got.Title)
+		assert.Equal(t, `"v1"`, v.ETag)
+		assert.Equal(t, "Sat, 10 Jul 2021 18:31:09 GMT", v.LastModified)
+		assert.NotEmpty(t, v.ContentHash)
+
+		_, err = ParseWithOptions(context.Background(), ts.URL+"/etag", ParseOpts{Validators: v})
+		assert.Equal(t, ErrNotModified, err)
+		assert.Equal(t, `"v1"`, v.ETag, "kept on 304")
+	})
+
+	t.Run("content hash", func(t *testing.T) {
+		v := &Validators{}
+		_, err := ParseWithOptions(context.Background(), ts.URL+"/plain", ParseOpts{Validators: v})
+		require.NoError(t, err)
+		assert.Empty(t, v.ETag)
+		assert.NotEmpty(t, v.ContentHash)
+
+		_, err = ParseWithOptions(context.Background(), ts.URL+"/plain", ParseOpts{Validators: v})
+		assert.Equal(t, ErrNotModified, err)
+
+		v.ContentHash = "other"
+		_, err = ParseWithOptions(context.Background(), ts.URL+"/plain", ParseOpts{Validators: v})
+		assert.NoError(t, err, "changed content parsed")
+	})
+}
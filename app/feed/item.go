@@ -1,10 +1,13 @@
 package feed
 
 import (
+	"context"
+	"crypto/sha1" // nolint
+	"fmt"
 	"html/template"
 	"io"
-	"net/http"
 	"path"
+	"strings"
 	"time"
 )
 
@@ -21,22 +24,78 @@ type Item struct {
 	Enclosure Enclosure     `xml:"enclosure"`
 	GUID      string        `xml:"guid"`
 	Author    string        `xml:"author,omitempty"`
+	Thumbnail string        `xml:"-"`        // preview image, from media:thumbnail
+	Tags      []string      `xml:"category"` // categories of the source item and tags added by feed-set
+
+	// iTunes podcast extension
+	ITunesDuration string       `xml:"itunes:duration,omitempty"`
+	ITunesImage    *ITunesImage `xml:"itunes:image,omitempty"`
+	ITunesEpisode  string       `xml:"itunes:episode,omitempty"`
+	ITunesSeason   string       `xml:"itunes:season,omitempty"`
+	ITunesExplicit string       `xml:"itunes:explicit,omitempty"`
+	ITunesAuthor   string       `xml:"itunes:author,omitempty"`
+	ITunesSummary  string       `xml:"itunes:summary,omitempty"`
+
+	// Podcasting 2.0 extension
+	PodcastTranscripts []PodcastTranscript `xml:"podcast:transcript,omitempty"`
+	PodcastChapters    *PodcastChapters    `xml:"podcast:chapters,omitempty"`
+	PodcastPersons     []PodcastPerson     `xml:"podcast:person,omitempty"`
+	PodcastFunding     []PodcastFunding    `xml:"-"` // source channel's funding, republished on the channel
+	PodcastFeedGUID    string              `xml:"-"` // source channel's podcast:guid
+
+	// elements not mapped to fields above, kept as is
+	Extensions []Extension `xml:",any"`
 
 	// Internal
-	DT   time.Time `xml:"-"`
-	Junk bool      `xml:"-"`
+	DT           time.Time `xml:"-"`
+	Junk         bool      `xml:"-"`
+	DateFallback bool      `xml:"-" json:"-"` // DT is channel's date, not item's own one
+	xmlBase      string    `xml:"-" json:"-"` // xml:base of the source item, used on parsing only
+}
+
+// ID returns stable identity of the item, guid with fallback to enclosure url, link and hash of title
+// with description. Empty for item without any of them.
+func (item Item) ID() string {
+	switch {
+	case item.GUID != "":
+		return item.GUID
+	case item.Enclosure.URL != "":
+		return item.Enclosure.URL
+	case item.Link != "":
+		return item.Link
+	case item.Title != "" || item.Description != "":
+		return fmt.Sprintf("sha1:%x", sha1.Sum([]byte(item.Title+"\x00"+string(item.Description)))) // nolint
+	default:
+		return ""
+	}
 }
 
-// DownloadAudio return httpBody for Item's Enclosure.URL
-func (item Item) DownloadAudio(timeout time.Duration) (io.ReadCloser, error) {
-	clientHTTP := &http.Client{Timeout: timeout}
+// HasTag checks if item has the tag, case-insensitive
+func (item Item) HasTag(tag string) bool {
+	for _, t := range item.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
 
-	resp, err := clientHTTP.Get(item.Enclosure.URL)
-	if err != nil {
-		return nil, err
+// AddTags adds tags missing in item, case-insensitive
+func (item *Item) AddTags(tags ...string) {
+	for _, t := range tags {
+		t = strings.TrimSpace(t)
+		if t != "" && !item.HasTag(t) {
+			item.Tags = append(item.Tags, t)
+		}
 	}
+}
 
-	return resp.Body, nil
+// DownloadAudio return httpBody for Item's Enclosure.URL, made by fetcher or by default one if nil
+func (item Item) DownloadAudio(ctx context.Context, fetcher *Fetcher, timeout time.Duration) (io.ReadCloser, error) {
+	if fetcher == nil {
+		fetcher = defaultFetcher
+	}
+	return fetcher.Stream(ctx, item.Enclosure.URL, timeout)
 }
 
 // GetFilename returns the filename for Item's Enclosure.URL
@@ -1,6 +1,7 @@
 package feed
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -42,7 +43,7 @@ func TestDownloadAudioIfRequestError(t *testing.T) {
 	defer ts.Close()
 
 	item := Item{Enclosure: Enclosure{URL: ts.URL}}
-	got, err := item.DownloadAudio(time.Minute)
+	got, err := item.DownloadAudio(context.Background(), nil, time.Minute)
 
 	assert.Nil(t, got)
 	assert.EqualError(t, err, fmt.Sprintf("Get %q: EOF", ts.URL))
@@ -57,8 +58,38 @@ func TestDownloadAudio(t *testing.T) {
 	defer ts.Close()
 
 	item := Item{Enclosure: Enclosure{URL: ts.URL}}
-	got, err := item.DownloadAudio(time.Minute)
+	got, err := item.DownloadAudio(context.Background(), nil, time.Minute)
 
 	assert.NotNil(t, got)
 	assert.Nil(t, err)
 }
+
+func TestItemID(t *testing.T) {
+	tbl := []struct {
+		item Item
+		id   string
+	}{
+		{Item{GUID: "guid", Link: "link", Enclosure: Enclosure{URL: "enc"}}, "guid"},
+		{Item{Link: "link", Enclosure: Enclosure{URL: "enc"}}, "enc"},
+		{Item{Link: "link"}, "link"},
+		{Item{Title: "title", Description: "descr"}, "sha1:b698282a48ea0c5e0ca4e74c58d7f9e8ca336ba0"},
+		{Item{Title: "title"}, "sha1:c06b9e7deeea4f15d1f31b1e407848bcfadedddf"},
+		{Item{}, ""},
+	}
+
+	for i, tt := range tbl {
+		tt := tt
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			assert.Equal(t, tt.id, tt.item.ID())
+		})
+	}
+}
+
+func TestItemTags(t *testing.T) {
+	item := Item{Tags: []string{"News"}}
+	assert.True(t, item.HasTag("news"))
+	assert.False(t, item.HasTag("culture"))
+
+	item.AddTags("culture", " NEWS ", "", " sport ", "Culture")
+	assert.Equal(t, []string{"News", "culture", "sport"}, item.Tags)
+}
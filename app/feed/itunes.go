@@ -0,0 +1,56 @@
+package feed
+
+import (
+	"encoding/xml"
+)
+
+// ITunesNS is namespace of iTunes podcast extension
+const ITunesNS = "http://www.itunes.com/dtds/podcast-1.0.dtd"
+
+// ITunesImage is itunes:image element, used by both channel and item
+type ITunesImage struct {
+	Href string `xml:"href,attr"`
+}
+
+// ITunesOwner is itunes:owner element of the channel
+type ITunesOwner struct {
+	Name  string `xml:"itunes:name,omitempty"`
+	Email string `xml:"itunes:email,omitempty"`
+}
+
+// ITunesCategory is itunes:category element of the channel, with optional subcategory
+type ITunesCategory struct {
+	Text        string          `xml:"text,attr"`
+	Subcategory *ITunesCategory `xml:"itunes:category,omitempty"`
+}
+
+// decodeITunes decodes itunes element of rss item to item's field, unknown elements skipped
+func (item *Item) decodeITunes(d *xml.Decoder, el xml.StartElement) error {
+	var dst interface{}
+	switch el.Name.Local {
+	case "duration":
+		dst = &item.ITunesDuration
+	case "episode":
+		dst = &item.ITunesEpisode
+	case "season":
+		dst = &item.ITunesSeason
+	case "explicit":
+		dst = &item.ITunesExplicit
+	case "author":
+		dst = &item.ITunesAuthor
+	case "summary":
+		dst = &item.ITunesSummary
+	case "image":
+		img := ITunesImage{}
+		if err := d.DecodeElement(&img, &el); err != nil {
+			return err
+		}
+		if img.Href != "" {
+			item.ITunesImage = &img
+		}
+		return nil
+	default:
+		return d.Skip()
+	}
+	return d.DecodeElement(dst, &el)
+}
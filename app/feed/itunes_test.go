@@ -0,0 +1,93 @@
+package feed
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseITunes(t *testing.T) {
+	rss := `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd">
+<channel>
+	<title>Podcast</title>
+	<itunes:image href="https://example.com/channel.png"/>
+	<item>
+		<title>Episode 2</title>
+		<guid>e2</guid>
+		<author>rss@example.com</author>
+		<itunes:duration>01:02:03</itunes:duration>
+		<itunes:image href="https://example.com/e2.png"/>
+		<itunes:episode>2</itunes:episode>
+		<itunes:season>1</itunes:season>
+		<itunes:explicit>false</itunes:explicit>
+		<itunes:author>Someone</itunes:author>
+		<itunes:summary>Episode summary</itunes:summary>
+	</item>
+	<item>
+		<title>Episode 1</title>
+		<guid>e1</guid>
+		<itunes:duration>3600</itunes:duration>
+	</item>
+</channel>
+</rss>`
+
+	got, err := parseFeedContent([]byte(rss))
+	require.NoError(t, err)
+	require.Len(t, got.ItemList, 2)
+
+	e2 := got.ItemList[0]
+	assert.Equal(t, "rss@example.com", e2.Author, "rss author not mixed with itunes one")
+	assert.Equal(t, "01:02:03", e2.ITunesDuration)
+	assert.Equal(t, &ITunesImage{Href: "https://example.com/e2.png"}, e2.ITunesImage)
+	assert.Equal(t, "2", e2.ITunesEpisode)
+	assert.Equal(t, "1", e2.ITunesSeason)
+	assert.Equal(t, "false", e2.ITunesExplicit)
+	assert.Equal(t, "Someone", e2.ITunesAuthor)
+	assert.Equal(t, "Episode summary", e2.ITunesSummary)
+
+	e1 := got.ItemList[1]
+	assert.Equal(t, "3600", e1.ITunesDuration)
+	assert.Equal(t, &ITunesImage{Href: "https://example.com/channel.png"}, e1.ITunesImage, "channel's image")
+	assert.Empty(t, e1.ITunesEpisode)
+}
+
+func TestMarshalITunes(t *testing.T) {
+	rss := Rss2{
+		Version:      "2.0",
+		Title:        "Podcast",
+		ITunesNS:     ITunesNS,
+		ITunesAuthor: "Author",
+		ITunesImage:  &ITunesImage{Href: "https://example.com/image/pod.png"},
+		ITunesOwner:  &ITunesOwner{Name: "Owner", Email: "owner@example.com"},
+		ITunesCategory: &ITunesCategory{Text: "Society & Culture",
+			Subcategory: &ITunesCategory{Text: "Documentary"}},
+		ITunesExplicit: "false",
+		ItemList: []Item{
+			{Title: "Episode 1", GUID: "e1", ITunesDuration: "3600", ITunesEpisode: "1"},
+			{Title: "Episode 2", GUID: "e2"},
+		},
+	}
+
+	b, err := xml.Marshal(&rss)
+	require.NoError(t, err)
+	res := string(b)
+	assert.Contains(t, res, `<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd">`)
+	assert.Contains(t, res, `<itunes:author>Author</itunes:author>`)
+	assert.Contains(t, res, `<itunes:image href="https://example.com/image/pod.png"></itunes:image>`)
+	assert.Contains(t, res, `<itunes:owner><itunes:name>Owner</itunes:name><itunes:email>owner@example.com</itunes:email></itunes:owner>`)
+	assert.Contains(t, res, `<itunes:category text="Society &amp; Culture"><itunes:category text="Documentary"></itunes:category></itunes:category>`)
+	assert.Contains(t, res, `<itunes:duration>3600</itunes:duration><itunes:episode>1</itunes:episode>`)
+	assert.Equal(t, 1, strings.Count(res, "<channel>"), "single channel element")
+	assert.Equal(t, 1, strings.Count(res, "<itunes:duration>"), "empty fields omitted")
+
+	// generated feed parsed back
+	got, err := parseFeedContent(b)
+	require.NoError(t, err)
+	require.Len(t, got.ItemList, 2)
+	assert.Equal(t, "3600", got.ItemList[0].ITunesDuration)
+	assert.Equal(t, "1", got.ItemList[0].ITunesEpisode)
+}
@@ -0,0 +1,216 @@
+package feed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"html/template"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Mapping defines JSONPath-style paths of item values in json api response, like "$.data.items" or
+// "media[0].url". Items path points to array of items and is relative to the response, the rest are
+// relative to each item. Items path defaults to the whole response, the other unset values left empty.
+type Mapping struct {
+	Items           string `yaml:"items"`
+	Title           string `yaml:"title"`
+	Link            string `yaml:"link"`
+	GUID            string `yaml:"guid"`
+	Date            string `yaml:"date"`        // date string or unix time in seconds or milliseconds
+	Description     string `yaml:"description"` // html
+	Enclosure       string `yaml:"enclosure"`   // enclosure url
+	EnclosureType   string `yaml:"enclosure_type"`
+	EnclosureLength string `yaml:"enclosure_length"`
+}
+
+// jsonPath is parsed path, step is either object key or array index
+type jsonPath []jsonStep
+
+type jsonStep struct {
+	key   string
+	index int
+	isIdx bool
+}
+
+// Validate checks paths of mapping are valid, title or link must be set
+func (m Mapping) Validate() error {
+	_, err := m.compile()
+	return err
+}
+
+type compiledMapping struct {
+	items, title, link, guid, date, description, enclosure, enclosureType, enclosureLength jsonPath
+}
+
+func (m Mapping) compile() (res compiledMapping, err error) {
+	if strings.TrimSpace(m.Title) == "" && strings.TrimSpace(m.Link) == "" {
+		return res, errors.New("neither title nor link mapped")
+	}
+
+	for _, p := range []struct {
+		name string
+		src  string
+		dst  *jsonPath
+	}{
+		{"items", m.Items, &res.items}, {"title", m.Title, &res.title}, {"link", m.Link, &res.link},
+		{"guid", m.GUID, &res.guid}, {"date", m.Date, &res.date}, {"description", m.Description, &res.description},
+		{"enclosure", m.Enclosure, &res.enclosure}, {"enclosure_type", m.EnclosureType, &res.enclosureType},
+		{"enclosure_length", m.EnclosureLength, &res.enclosureLength},
+	} {
+		if *p.dst, err = parseJSONPath(p.src); err != nil {
+			return res, errors.Wrapf(err, "invalid %s path %q", p.name, p.src)
+		}
+	}
+	return res, nil
+}
+
+// parseJSONPath parses dot-separated keys with optional array indexes, like "$.data[0].title" or "['a.b']".
+// Empty path and "$" refer to the value itself.
+func parseJSONPath(s string) (jsonPath, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "$")
+	res := jsonPath{}
+	for s != "" {
+		switch {
+		case strings.HasPrefix(s, "."):
+			s = s[1:]
+			end := strings.IndexAny(s, ".[")
+			if end == -1 {
+				end = len(s)
+			}
+			if end == 0 {
+				return nil, errors.New("empty key")
+			}
+			res = append(res, jsonStep{key: s[:end]})
+			s = s[end:]
+		case strings.HasPrefix(s, "['"):
+			end := strings.Index(s, "']")
+			if end == -1 {
+				return nil, errors.New("unclosed quoted key")
+			}
+			res = append(res, jsonStep{key: s[2:end]})
+			s = s[end+2:]
+		case strings.HasPrefix(s, "["):
+			end := strings.Index(s, "]")
+			if end == -1 {
+				return nil, errors.New("unclosed index")
+			}
+			idx, err := strconv.Atoi(s[1:end])
+			if err != nil || idx < 0 {
+				return nil, errors.Errorf("invalid index %q", s[1:end])
+			}
+			res = append(res, jsonStep{index: idx, isIdx: true})
+			s = s[end+1:]
+		default:
+			if len(res) > 0 {
+				return nil, errors.Errorf("unexpected %q", s)
+			}
+			s = "." + s // the first key without leading dot
+		}
+	}
+	return res, nil
+}
+
+// get returns value at the path, nil if missing
+func (p jsonPath) get(v interface{}) interface{} {
+	for _, step := range p {
+		switch vv := v.(type) {
+		case map[string]interface{}:
+			if step.isIdx {
+				return nil
+			}
+			v = vv[step.key]
+		case []interface{}:
+			if !step.isIdx || step.index >= len(vv) {
+				return nil
+			}
+			v = vv[step.index]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+// str returns scalar value at the path as string, empty for missing values, objects and arrays
+func (p jsonPath) str(v interface{}) string {
+	if len(p) == 0 {
+		return ""
+	}
+	switch vv := p.get(v).(type) {
+	case string:
+		return strings.TrimSpace(vv)
+	case json.Number:
+		return vv.String()
+	case bool:
+		return strconv.FormatBool(vv)
+	}
+	return ""
+}
+
+// ParseJSONAPI gets json api response with items and maps them to normalized Rss2 with given mapping.
+// opts used the same way as by ParseWithOptions.
+func ParseJSONAPI(ctx context.Context, uri string, m Mapping, opts ParseOpts) (Rss2, error) {
+	resp, err := fetchChanged(ctx, uri, opts)
+	if err != nil {
+		return Rss2{}, err
+	}
+	return parseJSONAPIBody(resp.Body, uri, m)
+}
+
+func parseJSONAPIBody(body []byte, uri string, m Mapping) (Rss2, error) {
+	cm, err := m.compile()
+	if err != nil {
+		return Rss2{}, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc interface{}
+	if err = dec.Decode(&doc); err != nil {
+		return Rss2{}, errors.Wrap(err, "can't parse json")
+	}
+
+	entries, ok := cm.items.get(doc).([]interface{})
+	if !ok {
+		return Rss2{}, errors.Errorf("no items array at %q", m.Items)
+	}
+
+	result := Rss2{Version: "2.0", Link: uri, FeedURL: uri}
+	for _, e := range entries {
+		item := Item{
+			Title:   cm.title.str(e),
+			Link:    cm.link.str(e),
+			GUID:    cm.guid.str(e),
+			PubDate: jsonDate(cm.date.str(e)),
+		}
+		item.Description = template.HTML(cm.description.str(e)) // nolint
+		if u := cm.enclosure.str(e); u != "" {
+			item.Enclosure = Enclosure{URL: u, Type: cm.enclosureType.str(e)}
+			item.Enclosure.Length, _ = strconv.Atoi(cm.enclosureLength.str(e))
+		}
+		if item.Title == "" && item.Link == "" {
+			continue
+		}
+		result.ItemList = append(result.ItemList, item)
+	}
+	return result.Normalize()
+}
+
+// jsonDate converts unix time in seconds or milliseconds to RFC1123Z, returns other dates as is
+func jsonDate(dt string) string {
+	ts, err := strconv.ParseFloat(dt, 64)
+	if err != nil || ts <= 0 {
+		return dt
+	}
+	if ts > 1e11 { // milliseconds, as seconds it would be year 5138
+		ts /= 1000
+	}
+	sec, frac := math.Modf(ts)
+	return time.Unix(int64(sec), int64(frac*1e9)).UTC().Format(time.RFC1123Z)
+}
@@ -0,0 +1,91 @@
+package feed
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseJSONAPI(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"result": {"episodes": [
+			{"id": 3, "title": "Episode 3", "url": "/ep/3", "published": "2021-07-10T18:30:00Z",
+				"notes": "<p>notes <a href=\"more\">more</a></p>",
+				"files": [{"src": "/ep3.mp3", "mime": "audio/mpeg", "size": 12345}]},
+			{"id": 2, "title": "Episode 2", "url": "https://example.com/ep/2", "published": 1625941800000, "files": []},
+			{"id": 1, "title": "", "published": 1625941800},
+			{"id": 0, "title": {"nested": "object"}, "url": true}
+		]}}`))
+	}))
+	defer ts.Close()
+
+	m := Mapping{Items: "$.result.episodes", Title: "title", Link: "url", GUID: "id", Date: "published",
+		Description: "notes", Enclosure: "files[0].src", EnclosureType: "files[0].mime", EnclosureLength: "files[0]['size']"}
+	rss, err := ParseJSONAPI(context.Background(), ts.URL+"/api/episodes", m, ParseOpts{})
+	require.NoError(t, err)
+	assert.Equal(t, ts.URL+"/api/episodes", rss.Link)
+	require.Len(t, rss.ItemList, 3)
+
+	ep3 := rss.ItemList[0]
+	assert.Equal(t, "Episode 3", ep3.Title)
+	assert.Equal(t, ts.URL+"/ep/3", ep3.Link)
+	assert.Equal(t, "3", ep3.GUID)
+	assert.Equal(t, time.Date(2021, 7, 10, 18, 30, 0, 0, time.UTC), ep3.DT.UTC())
+	assert.Equal(t, `<p>notes <a href="`+ts.URL+`/ep/more">more</a></p>`, string(ep3.Description))
+	assert.Equal(t, Enclosure{URL: ts.URL + "/ep3.mp3", Type: "audio/mpeg", Length: 12345}, ep3.Enclosure)
+
+	ep2 := rss.ItemList[1]
+	assert.Equal(t, "https://example.com/ep/2", ep2.Link)
+	assert.Equal(t, time.Date(2021, 7, 10, 18, 30, 0, 0, time.UTC), ep2.DT.UTC(), "milliseconds")
+	assert.Equal(t, Enclosure{}, ep2.Enclosure)
+
+	assert.Equal(t, ts.URL+"/api/true", rss.ItemList[2].Link, "scalar converted, object ignored")
+	assert.Equal(t, "", rss.ItemList[2].Title)
+
+	_, err = ParseJSONAPI(context.Background(), ts.URL, Mapping{Items: "result", Title: "title"}, ParseOpts{})
+	assert.EqualError(t, err, `no items array at "result"`)
+}
+
+func TestParseJSONPath(t *testing.T) {
+	tbl := []struct {
+		path string
+		res  jsonPath
+		err  string
+	}{
+		{"", jsonPath{}, ""},
+		{"$", jsonPath{}, ""},
+		{"$.data.items", jsonPath{{key: "data"}, {key: "items"}}, ""},
+		{"data.items[2].url", jsonPath{{key: "data"}, {key: "items"}, {index: 2, isIdx: true}, {key: "url"}}, ""},
+		{"$[0]['a.b']", jsonPath{{index: 0, isIdx: true}, {key: "a.b"}}, ""},
+		{"$..a", nil, "empty key"},
+		{"a[-1]", nil, `invalid index "-1"`},
+		{"a[1", nil, "unclosed index"},
+		{"['a", nil, "unclosed quoted key"},
+		{"a[0]b", nil, `unexpected "b"`},
+	}
+	for i, tt := range tbl {
+		tt := tt
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			res, err := parseJSONPath(tt.path)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.res, res)
+		})
+	}
+}
+
+func TestMappingValidate(t *testing.T) {
+	assert.EqualError(t, Mapping{Items: "data"}.Validate(), "neither title nor link mapped")
+	assert.EqualError(t, Mapping{Link: "url", GUID: "ids[a]"}.Validate(), `invalid guid path "ids[a]": invalid index "a"`)
+	assert.NoError(t, Mapping{Link: "url"}.Validate())
+}
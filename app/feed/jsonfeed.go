@@ -0,0 +1,190 @@
+package feed
+
+import (
+	"encoding/json"
+	"html"
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// JSONFeedVersion is the version of generated JSON Feed
+const JSONFeedVersion = "https://jsonfeed.org/version/1.1"
+
+// JSONFeed is JSON Feed (https://jsonfeed.org), used for both parsing of 1.0/1.1 and rendering of 1.1
+type JSONFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url,omitempty"`
+	FeedURL     string           `json:"feed_url,omitempty"`
+	Description string           `json:"description,omitempty"`
+	Icon        string           `json:"icon,omitempty"`
+	Language    string           `json:"language,omitempty"`
+	Authors     []JSONFeedAuthor `json:"authors,omitempty"`
+	Author      *JSONFeedAuthor  `json:"author,omitempty"` // 1.0 only, deprecated in 1.1
+	Items       []JSONFeedItem   `json:"items"`
+}
+
+// JSONFeedItem is item of JSON Feed
+type JSONFeedItem struct {
+	ID            string               `json:"id"`
+	URL           string               `json:"url,omitempty"`
+	ExternalURL   string               `json:"external_url,omitempty"`
+	Title         string               `json:"title,omitempty"`
+	ContentHTML   string               `json:"content_html,omitempty"`
+	ContentText   string               `json:"content_text,omitempty"`
+	Summary       string               `json:"summary,omitempty"`
+	Image         string               `json:"image,omitempty"`
+	DatePublished string               `json:"date_published,omitempty"`
+	DateModified  string               `json:"date_modified,omitempty"`
+	Authors       []JSONFeedAuthor     `json:"authors,omitempty"`
+	Author        *JSONFeedAuthor      `json:"author,omitempty"` // 1.0 only, deprecated in 1.1
+	Tags          []string             `json:"tags,omitempty"`
+	Attachments   []JSONFeedAttachment `json:"attachments,omitempty"`
+}
+
+// JSONFeedAuthor is author of JSON Feed or its item
+type JSONFeedAuthor struct {
+	Name   string `json:"name,omitempty"`
+	URL    string `json:"url,omitempty"`
+	Avatar string `json:"avatar,omitempty"`
+}
+
+// JSONFeedAttachment is attachment of JSON Feed item
+type JSONFeedAttachment struct {
+	URL               string `json:"url"`
+	MimeType          string `json:"mime_type"`
+	Title             string `json:"title,omitempty"`
+	SizeInBytes       int    `json:"size_in_bytes,omitempty"`
+	DurationInSeconds int    `json:"duration_in_seconds,omitempty"`
+}
+
+func parseJSONFeed(content []byte) (Rss2, error) {
+	v := JSONFeed{}
+	if err := json.Unmarshal(content, &v); err != nil {
+		return Rss2{}, errors.Wrap(err, "can't parse json feed")
+	}
+	if !strings.HasPrefix(v.Version, "https://jsonfeed.org/version/") {
+		return Rss2{}, errors.Errorf("not a json feed, version %q", v.Version)
+	}
+	return jsonFeedToRss2(v), nil
+}
+
+func jsonFeedToRss2(v JSONFeed) Rss2 {
+	r := Rss2{
+		Version:     "2.0",
+		Title:       v.Title,
+		Link:        v.HomePageURL,
+		Description: v.Description,
+		Language:    v.Language,
+	}
+
+	r.ItemList = make([]Item, len(v.Items))
+	for i, entry := range v.Items {
+		item := Item{
+			GUID:      entry.ID,
+			Title:     strings.TrimSpace(entry.Title),
+			Link:      entry.URL,
+			PubDate:   pubDate(entry.DatePublished, entry.DateModified),
+			Thumbnail: entry.Image,
+			Tags:      entry.Tags,
+		}
+		if item.Link == "" {
+			item.Link = entry.ExternalURL
+		}
+
+		switch {
+		case entry.ContentHTML != "":
+			item.Description = template.HTML(entry.ContentHTML) // nolint
+		case entry.ContentText != "":
+			item.Description = template.HTML(html.EscapeString(entry.ContentText)) // nolint
+		default:
+			item.Description = template.HTML(html.EscapeString(entry.Summary)) // nolint
+		}
+
+		item.Author = jsonFeedAuthors(entry.Authors, entry.Author)
+		if item.Author == "" {
+			item.Author = jsonFeedAuthors(v.Authors, v.Author)
+		}
+
+		if att, ok := jsonFeedEnclosure(entry.Attachments); ok {
+			item.Enclosure = Enclosure{URL: att.URL, Type: att.MimeType, Length: att.SizeInBytes}
+		}
+		r.ItemList[i] = item
+	}
+	return r
+}
+
+// jsonFeedEnclosure picks audio or video attachment, the first one otherwise
+func jsonFeedEnclosure(attachments []JSONFeedAttachment) (JSONFeedAttachment, bool) {
+	if len(attachments) == 0 {
+		return JSONFeedAttachment{}, false
+	}
+	for _, att := range attachments {
+		if strings.HasPrefix(att.MimeType, "audio/") || strings.HasPrefix(att.MimeType, "video/") {
+			return att, true
+		}
+	}
+	return attachments[0], true
+}
+
+func jsonFeedAuthors(authors []JSONFeedAuthor, author *JSONFeedAuthor) string {
+	if author != nil {
+		authors = append(authors, *author)
+	}
+	res := make([]string, 0, len(authors))
+	for _, a := range authors {
+		if name := strings.TrimSpace(a.Name); name != "" {
+			res = append(res, name)
+		}
+	}
+	return strings.Join(res, ", ")
+}
+
+// NewJSONFeed makes JSON Feed 1.1 from rss, feedURL is the url of generated json feed
+func NewJSONFeed(rss Rss2, feedURL string) JSONFeed {
+	res := JSONFeed{
+		Version:     JSONFeedVersion,
+		Title:       rss.Title,
+		HomePageURL: rss.Link,
+		FeedURL:     feedURL,
+		Description: rss.Description,
+		Language:    rss.Language,
+		Items:       make([]JSONFeedItem, len(rss.ItemList)),
+	}
+
+	for i, item := range rss.ItemList {
+		entry := JSONFeedItem{
+			ID:          item.ID(),
+			URL:         item.Link,
+			Title:       item.Title,
+			ContentHTML: string(item.Description),
+			Image:       item.Thumbnail,
+			Tags:        item.Tags,
+		}
+		if entry.ContentHTML == "" {
+			entry.ContentText = item.Title // either content_html or content_text required
+		}
+
+		dt := item.DT
+		if dt.IsZero() {
+			dt, _ = time.Parse(time.RFC1123Z, item.PubDate) // zero time on error, skipped below
+		}
+		if !dt.IsZero() {
+			entry.DatePublished = dt.Format(time.RFC3339)
+		}
+
+		if item.Author != "" {
+			entry.Authors = []JSONFeedAuthor{{Name: item.Author}}
+		}
+		if item.Enclosure.URL != "" {
+			entry.Attachments = []JSONFeedAttachment{{
+				URL: item.Enclosure.URL, MimeType: item.Enclosure.Type, SizeInBytes: item.Enclosure.Length,
+			}}
+		}
+		res.Items[i] = entry
+	}
+	return res
+}
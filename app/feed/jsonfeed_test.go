@@ -0,0 +1,121 @@
+package feed
+
+import (
+	"encoding/json"
+	"html/template"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFeedContentJSONFeed(t *testing.T) {
+	jf := "\xef\xbb\xbf" + `{
+  "version": "https://jsonfeed.org/version/1.1",
+  "title": "JSON Podcast",
+  "home_page_url": "https://example.org/",
+  "feed_url": "https://example.org/feed.json",
+  "description": "podcast in json",
+  "language": "en",
+  "authors": [{"name": "Feed Author"}],
+  "items": [
+    {
+      "id": "2",
+      "url": "https://example.org/2",
+      "title": "Episode 2",
+      "content_html": "<p>Episode <b>two</b></p>",
+      "date_published": "2021-07-10T18:30:02+03:00",
+      "authors": [{"name": "Host 1"}, {"name": "Host 2"}],
+      "attachments": [
+        {"url": "https://example.org/2.txt", "mime_type": "text/plain"},
+        {"url": "https://example.org/2.mp3", "mime_type": "audio/mpeg", "size_in_bytes": 100500, "duration_in_seconds": 60}
+      ]
+    },
+    {
+      "id": "1",
+      "external_url": "https://other.example.com/1",
+      "content_text": "plain <text>",
+      "date_modified": "2021-07-09T10:00:00Z"
+    }
+  ]
+}`
+
+	content, err := toUTF8([]byte(jf), "") // BOM stripped by parseBody
+	require.NoError(t, err)
+	got, err := parseFeedContent(content)
+	require.NoError(t, err)
+	assert.Equal(t, "JSON Podcast", got.Title)
+	assert.Equal(t, "https://example.org/", got.Link)
+	assert.Equal(t, "podcast in json", got.Description)
+	assert.Equal(t, "en", got.Language)
+	require.Len(t, got.ItemList, 2)
+
+	item := got.ItemList[0]
+	assert.Equal(t, "2", item.GUID)
+	assert.Equal(t, "Episode 2", item.Title)
+	assert.Equal(t, "https://example.org/2", item.Link)
+	assert.Equal(t, template.HTML("<p>Episode <b>two</b></p>"), item.Description)
+	assert.Equal(t, "Sat, 10 Jul 2021 18:30:02 +0300", item.PubDate)
+	assert.Equal(t, "Host 1, Host 2", item.Author)
+	assert.Equal(t, Enclosure{URL: "https://example.org/2.mp3", Type: "audio/mpeg", Length: 100500}, item.Enclosure)
+
+	item = got.ItemList[1]
+	assert.Equal(t, "https://other.example.com/1", item.Link)
+	assert.Equal(t, template.HTML("plain &lt;text&gt;"), item.Description)
+	assert.Equal(t, "Fri, 09 Jul 2021 10:00:00 +0000", item.PubDate)
+	assert.Equal(t, "Feed Author", item.Author)
+	assert.Equal(t, Enclosure{}, item.Enclosure)
+}
+
+func TestParseFeedContentJSONFeed10(t *testing.T) {
+	jf := `{"version": "https://jsonfeed.org/version/1", "title": "old", "author": {"name": "Old Author"},
+		"items": [{"id": "1", "content_text": "txt"}]}`
+	got, err := parseFeedContent([]byte(jf))
+	require.NoError(t, err)
+	require.Len(t, got.ItemList, 1)
+	assert.Equal(t, "Old Author", got.ItemList[0].Author)
+}
+
+func TestParseFeedContentJSONFeedInvalid(t *testing.T) {
+	_, err := parseFeedContent([]byte(`{"title": "no version"}`))
+	assert.EqualError(t, err, `not a json feed, version ""`)
+
+	_, err = parseFeedContent([]byte(`{"version": `))
+	assert.EqualError(t, err, "can't parse json feed: unexpected end of JSON input")
+}
+
+func TestNewJSONFeed(t *testing.T) {
+	dt := time.Date(2021, 7, 10, 18, 30, 2, 0, time.UTC)
+	rss := Rss2{
+		Title:       "Feed",
+		Link:        "https://example.com/feed/fm",
+		Description: "Desc",
+		Language:    "ru",
+		ItemList: []Item{
+			{GUID: "g1", Title: "Item 1", Link: "https://example.com/1", Description: "<p>d1</p>", DT: dt, Author: "Host",
+				Enclosure: Enclosure{URL: "https://example.com/1.mp3", Type: "audio/mpeg", Length: 100}},
+			{Title: "Item 2", Link: "https://example.com/2", PubDate: "Fri, 09 Jul 2021 10:00:00 +0000"},
+		},
+	}
+
+	jf := NewJSONFeed(rss, "https://example.com/json/fm")
+	assert.Equal(t, JSONFeedVersion, jf.Version)
+	assert.Equal(t, "https://example.com/json/fm", jf.FeedURL)
+	assert.Equal(t, "https://example.com/feed/fm", jf.HomePageURL)
+	require.Len(t, jf.Items, 2)
+	assert.Equal(t, JSONFeedItem{ID: "g1", URL: "https://example.com/1", Title: "Item 1", ContentHTML: "<p>d1</p>",
+		DatePublished: "2021-07-10T18:30:02Z", Authors: []JSONFeedAuthor{{Name: "Host"}},
+		Attachments: []JSONFeedAttachment{{URL: "https://example.com/1.mp3", MimeType: "audio/mpeg", SizeInBytes: 100}}}, jf.Items[0])
+	assert.Equal(t, JSONFeedItem{ID: "https://example.com/2", URL: "https://example.com/2", Title: "Item 2", ContentText: "Item 2",
+		DatePublished: "2021-07-09T10:00:00Z"}, jf.Items[1])
+
+	// round trip
+	b, err := json.Marshal(jf)
+	require.NoError(t, err)
+	parsed, err := parseFeedContent(b)
+	require.NoError(t, err)
+	require.Len(t, parsed.ItemList, 2)
+	assert.Equal(t, "g1", parsed.ItemList[0].GUID)
+	assert.Equal(t, rss.ItemList[0].Enclosure, parsed.ItemList[0].Enclosure)
+}
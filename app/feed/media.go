@@ -0,0 +1,149 @@
+package feed
+
+import (
+	"encoding/xml"
+	"html"
+	"html/template"
+	"strings"
+)
+
+const (
+	mediaNS   = "http://search.yahoo.com/mrss/"
+	youTubeNS = "http://www.youtube.com/xml/schemas/2015"
+)
+
+// MediaElements are Media RSS (media:) and YouTube (yt:) elements of rss item or atom entry.
+// Media elements may be set directly or grouped with media:group, as YouTube does.
+type MediaElements struct {
+	MediaGroups []MediaGroup `xml:"http://search.yahoo.com/mrss/ group"`
+	MediaGroup
+	YouTubeVideoID string `xml:"http://www.youtube.com/xml/schemas/2015 videoId"`
+}
+
+// MediaGroup is a set of media elements
+type MediaGroup struct {
+	Contents    []MediaContent   `xml:"http://search.yahoo.com/mrss/ content"`
+	Thumbnails  []MediaThumbnail `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+	Description MediaDescription `xml:"http://search.yahoo.com/mrss/ description"`
+}
+
+// MediaContent is media:content element
+type MediaContent struct {
+	URL       string `xml:"url,attr"`
+	Type      string `xml:"type,attr"`
+	Medium    string `xml:"medium,attr"`
+	FileSize  int    `xml:"fileSize,attr"`
+	IsDefault bool   `xml:"isDefault,attr"`
+}
+
+// MediaThumbnail is media:thumbnail element
+type MediaThumbnail struct {
+	URL string `xml:"url,attr"`
+}
+
+// MediaDescription is media:description element, plain text unless type is html
+type MediaDescription struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// decode decodes media or youtube element of rss item, unknown elements skipped
+func (m *MediaElements) decode(d *xml.Decoder, el xml.StartElement) error {
+	if el.Name.Space == youTubeNS {
+		if el.Name.Local == "videoId" {
+			return d.DecodeElement(&m.YouTubeVideoID, &el)
+		}
+		return d.Skip()
+	}
+
+	switch el.Name.Local {
+	case "group":
+		g := MediaGroup{}
+		if err := d.DecodeElement(&g, &el); err != nil {
+			return err
+		}
+		m.MediaGroups = append(m.MediaGroups, g)
+		return nil
+	case "content":
+		c := MediaContent{}
+		if err := d.DecodeElement(&c, &el); err != nil {
+			return err
+		}
+		m.Contents = append(m.Contents, c)
+		return nil
+	case "thumbnail":
+		t := MediaThumbnail{}
+		if err := d.DecodeElement(&t, &el); err != nil {
+			return err
+		}
+		m.Thumbnails = append(m.Thumbnails, t)
+		return nil
+	case "description":
+		return d.DecodeElement(&m.Description, &el)
+	}
+	return d.Skip()
+}
+
+// apply sets enclosure, thumbnail, description and link of the item from media elements, if not set yet.
+// Relative urls resolved against base.
+func (m MediaElements) apply(item *Item, base string) {
+	groups := append([]MediaGroup{m.MediaGroup}, m.MediaGroups...)
+
+	if c, ok := m.content(groups); ok && item.Enclosure.URL == "" {
+		item.Enclosure = Enclosure{URL: resolveURL(base, c.URL), Type: c.Type, Length: c.FileSize}
+	}
+
+	for _, g := range groups {
+		if len(g.Thumbnails) > 0 && g.Thumbnails[0].URL != "" && item.Thumbnail == "" {
+			item.Thumbnail = resolveURL(base, g.Thumbnails[0].URL)
+		}
+		if desc := g.Description.html(); desc != "" && item.Description == "" {
+			item.Description = desc
+		}
+	}
+
+	if id := strings.TrimSpace(m.YouTubeVideoID); id != "" {
+		if item.Link == "" {
+			item.Link = "https://www.youtube.com/watch?v=" + id
+		}
+		if item.Thumbnail == "" {
+			item.Thumbnail = "https://i.ytimg.com/vi/" + id + "/hqdefault.jpg"
+		}
+	}
+}
+
+// content picks media content for enclosure, the default one, then the first audio or video, then the first of all
+func (m MediaElements) content(groups []MediaGroup) (MediaContent, bool) {
+	var all []MediaContent
+	for _, g := range groups {
+		for _, c := range g.Contents {
+			if c.URL != "" {
+				all = append(all, c)
+			}
+		}
+	}
+	if len(all) == 0 {
+		return MediaContent{}, false
+	}
+
+	for _, c := range all {
+		if c.IsDefault {
+			return c, true
+		}
+	}
+	for _, c := range all {
+		if c.Medium == "audio" || c.Medium == "video" || strings.HasPrefix(c.Type, "audio/") || strings.HasPrefix(c.Type, "video/") {
+			return c, true
+		}
+	}
+	return all[0], true
+}
+
+// html returns html of media description, plain text escaped with line breaks kept
+func (d MediaDescription) html() template.HTML {
+	body := strings.TrimSpace(d.Body)
+	if d.Type == "html" {
+		return template.HTML(body) // nolint
+	}
+	return template.HTML(strings.ReplaceAll(html.EscapeString(body), "\n", "<br>")) // nolint
+}
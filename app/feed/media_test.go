@@ -0,0 +1,110 @@
+package feed
+
+import (
+	"html/template"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseYouTubeAtom(t *testing.T) {
+	atom := `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns:yt="http://www.youtube.com/xml/schemas/2015" xmlns:media="http://search.yahoo.com/mrss/" xmlns="http://www.w3.org/2005/Atom">
+ <id>yt:channel:UC123</id>
+ <title>Channel</title>
+ <link rel="alternate" href="https://www.youtube.com/channel/UC123"/>
+ <entry>
+  <id>yt:video:abc123</id>
+  <yt:videoId>abc123</yt:videoId>
+  <title>Video title</title>
+  <link rel="alternate" href="https://www.youtube.com/watch?v=abc123"/>
+  <published>2021-07-10T15:00:00+00:00</published>
+  <media:group>
+   <media:title>Video title</media:title>
+   <media:content url="https://www.youtube.com/v/abc123?version=3" type="application/x-shockwave-flash" width="640" height="390"/>
+   <media:thumbnail url="https://i2.ytimg.com/vi/abc123/hqdefault.jpg" width="480" height="360"/>
+   <media:description>Line 1 &lt;b&gt;
+Line 2</media:description>
+  </media:group>
+ </entry>
+ <entry>
+  <id>yt:video:def456</id>
+  <yt:videoId>def456</yt:videoId>
+  <title>No group</title>
+  <published>2021-07-09T15:00:00+00:00</published>
+ </entry>
+</feed>`
+
+	got, err := parseFeedContent([]byte(atom))
+	require.NoError(t, err)
+	require.Len(t, got.ItemList, 2)
+
+	item := got.ItemList[0]
+	assert.Equal(t, "Video title", item.Title)
+	assert.Equal(t, "yt:video:abc123", item.GUID)
+	assert.Equal(t, "https://www.youtube.com/watch?v=abc123", item.Link)
+	assert.Equal(t, Enclosure{URL: "https://www.youtube.com/v/abc123?version=3", Type: "application/x-shockwave-flash"}, item.Enclosure)
+	assert.Equal(t, "https://i2.ytimg.com/vi/abc123/hqdefault.jpg", item.Thumbnail)
+	assert.Equal(t, template.HTML("Line 1 &lt;b&gt;<br>Line 2"), item.Description)
+
+	item = got.ItemList[1]
+	assert.Equal(t, "https://www.youtube.com/watch?v=def456", item.Link, "link made from video id")
+	assert.Equal(t, "https://i.ytimg.com/vi/def456/hqdefault.jpg", item.Thumbnail, "thumbnail made from video id")
+	assert.Empty(t, item.Enclosure.URL)
+}
+
+func TestParseMediaRss(t *testing.T) {
+	rss := `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:media="http://search.yahoo.com/mrss/">
+<channel>
+	<title>Media feed</title>
+	<item>
+		<title>Item 1</title>
+		<guid>1</guid>
+		<description>rss description</description>
+		<media:title>media title</media:title>
+		<media:description>media description</media:description>
+		<media:content url="https://example.com/1.jpg" medium="image"/>
+		<media:content url="https://example.com/1.mp3" type="audio/mpeg" fileSize="12345"/>
+		<media:thumbnail url="https://example.com/1-thumb.jpg"/>
+	</item>
+	<item>
+		<title>Item 2</title>
+		<guid>2</guid>
+		<enclosure url="https://example.com/2.mp3" type="audio/mpeg" length="100"/>
+		<media:group>
+			<media:content url="https://example.com/2-low.mp3" type="audio/mpeg"/>
+			<media:content url="https://example.com/2-high.mp3" type="audio/mpeg" isDefault="true"/>
+			<media:description type="html">&lt;p&gt;html description&lt;/p&gt;</media:description>
+		</media:group>
+	</item>
+	<item>
+		<title>Item 3</title>
+		<guid>3</guid>
+		<media:group>
+			<media:content url="https://example.com/3-low.mp3" type="audio/mpeg"/>
+			<media:content url="https://example.com/3-high.mp3" type="audio/mpeg" isDefault="true"/>
+		</media:group>
+	</item>
+</channel>
+</rss>`
+
+	got, err := parseFeedContent([]byte(rss))
+	require.NoError(t, err)
+	require.Len(t, got.ItemList, 3)
+
+	item := got.ItemList[0]
+	assert.Equal(t, "Item 1", item.Title, "not replaced by media:title")
+	assert.Equal(t, template.HTML("rss description"), item.Description, "not replaced by media:description")
+	assert.Equal(t, Enclosure{URL: "https://example.com/1.mp3", Type: "audio/mpeg", Length: 12345}, item.Enclosure, "audio preferred")
+	assert.Equal(t, "https://example.com/1-thumb.jpg", item.Thumbnail)
+
+	item = got.ItemList[1]
+	assert.Equal(t, Enclosure{URL: "https://example.com/2.mp3", Type: "audio/mpeg", Length: 100}, item.Enclosure, "rss enclosure kept")
+	assert.Equal(t, template.HTML("<p>html description</p>"), item.Description)
+
+	item = got.ItemList[2]
+	assert.Equal(t, "https://example.com/3-high.mp3", item.Enclosure.URL, "default content")
+	assert.Empty(t, item.Description)
+}
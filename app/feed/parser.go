@@ -4,12 +4,13 @@ package feed
 // based on http://siongui.github.io/2015/03/03/go-parse-web-feed-rss-atom/
 
 import (
-	"bufio"
 	"bytes"
+	"context"
+	"crypto/sha256"
 	"encoding/xml"
 	"fmt"
-	"html/template"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"strings"
 	"time"
@@ -28,6 +29,21 @@ type Rss2 struct {
 	Description   string   `xml:"channel>description"`
 	PubDate       string   `xml:"channel>pubDate"`
 	LastBuildDate string   `xml:"channel>lastBuildDate"`
+	FeedURL       string   `xml:"-"` // url the feed fetched from, base for relative urls
+
+	// iTunes podcast extension, set for generated feeds
+	ITunesNS       string          `xml:"xmlns:itunes,attr,omitempty"`
+	ITunesAuthor   string          `xml:"channel>itunes:author,omitempty"`
+	ITunesSummary  string          `xml:"channel>itunes:summary,omitempty"`
+	ITunesImage    *ITunesImage    `xml:"channel>itunes:image,omitempty"`
+	ITunesOwner    *ITunesOwner    `xml:"channel>itunes:owner,omitempty"`
+	ITunesCategory *ITunesCategory `xml:"channel>itunes:category,omitempty"`
+	ITunesExplicit string          `xml:"channel>itunes:explicit,omitempty"`
+
+	// Podcasting 2.0 extension, set for generated feeds
+	PodcastNS      string           `xml:"xmlns:podcast,attr,omitempty"`
+	PodcastGUID    string           `xml:"channel>podcast:guid,omitempty"`
+	PodcastFunding []PodcastFunding `xml:"channel>podcast:funding,omitempty"`
 
 	ItemList []Item `xml:"channel>item"`
 }
@@ -39,164 +55,392 @@ type Enclosure struct {
 	Type   string `xml:"type,attr"`
 }
 
-// Atom1 is atom feed
-type Atom1 struct {
-	XMLName   xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
-	Title     string   `xml:"title"`
-	Subtitle  string   `xml:"subtitle"`
-	ID        string   `xml:"id"`
-	Updated   string   `xml:"updated"`
-	Rights    string   `xml:"rights"`
-	Link      Link     `xml:"link"`
-	Author    Author   `xml:"author"`
-	EntryList []Entry  `xml:"entry"`
+// ParseOpts defines optional parameters of ParseWithOptions
+type ParseOpts struct {
+	Fetcher    *Fetcher      // default fetcher used if nil
+	Timeout    time.Duration // overrides fetcher's timeout if set
+	Validators *Validators   // makes conditional request if set, updated from response
 }
 
-// Link element for xml
-type Link struct {
-	Href string `xml:"href,attr"`
+// Validators of the previously fetched content, used for conditional GET
+type Validators struct {
+	ETag         string
+	LastModified string
+	ContentHash  string
 }
 
-// Author element for xml
-type Author struct {
-	Name  string `xml:"name"`
-	Email string `xml:"email"`
+// ErrNotModified returned by ParseWithOptions if content not changed since the last fetch
+var ErrNotModified = errors.New("not modified")
+
+// Parse gets url to rss feed and returns Rss2 items
+func Parse(uri string) (result Rss2, err error) {
+	return ParseWithOptions(context.Background(), uri, ParseOpts{})
 }
 
-// Entry from atom
-type Entry struct {
-	Title     string    `xml:"title"`
-	Summary   string    `xml:"summary"`
-	Content   string    `xml:"content"`
-	ID        string    `xml:"id"`
-	Updated   string    `xml:"updated"`
-	Link      Link      `xml:"link"`
-	Author    Author    `xml:"author"`
-	Enclosure Enclosure `xml:"enclosure"`
+// ParseWithOptions gets url to feed with given fetcher and timeout and returns normalized Rss2.
+// With opts.Validators the request is conditional, and ErrNotModified returned for 304 response
+// or for the body with the same hash as before. Validators updated in place in both cases.
+func ParseWithOptions(ctx context.Context, uri string, opts ParseOpts) (Rss2, error) {
+	resp, err := fetchChanged(ctx, uri, opts)
+	if err != nil {
+		return Rss2{}, err
+	}
+	return parseBody(resp.Body, resp.Header.Get("Content-Type"), uri)
 }
 
-// Parse gets url to rss feed and returns Rss2 items
-func Parse(uri string) (result Rss2, err error) {
-	resp, err := http.Get(uri) // nolint
+// fetchChanged gets uri with opts, conditionally if opts.Validators set.
+// Returns ErrNotModified for 304 response or unchanged body.
+func fetchChanged(ctx context.Context, uri string, opts ParseOpts) (*Response, error) {
+	fetcher := opts.Fetcher
+	if fetcher == nil {
+		fetcher = defaultFetcher
+	}
+	if opts.Timeout > 0 {
+		fetcher = fetcher.WithTimeout(opts.Timeout)
+	}
+
+	resp, err := fetcher.Fetch(ctx, uri, opts.Validators.header())
 	if err != nil {
-		return result, err
+		return nil, err
 	}
-	defer func() {
-		if e := resp.Body.Close(); e != nil {
-			log.Printf("[WARN] failed to close body, %s", e)
+
+	if v := opts.Validators; v != nil {
+		if resp.StatusCode == http.StatusNotModified {
+			v.update(resp.Header)
+			return nil, ErrNotModified
+		}
+		hash := contentHash(resp.Body)
+		unchanged := v.ContentHash != "" && v.ContentHash == hash
+		v.update(resp.Header)
+		v.ContentHash = hash
+		if unchanged {
+			return nil, ErrNotModified
 		}
-	}()
+	}
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, errors.Errorf("%s not modified, but no validators sent", uri)
+	}
+	return resp, nil
+}
+
+// header makes conditional request headers, nil for nil validators
+func (v *Validators) header() http.Header {
+	if v == nil {
+		return nil
+	}
+	h := http.Header{}
+	if v.ETag != "" {
+		h.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		h.Set("If-Modified-Since", v.LastModified)
+	}
+	return h
+}
+
+// update sets validators from response headers, keeps the old ones if missing (as allowed for 304)
+func (v *Validators) update(h http.Header) {
+	if etag := h.Get("ETag"); etag != "" {
+		v.ETag = etag
+	}
+	if lm := h.Get("Last-Modified"); lm != "" {
+		v.LastModified = lm
+	}
+}
+
+func contentHash(body []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(body))
+}
+
+// ParseReader reads feed content and returns normalized Rss2. Content type is optional,
+// used to detect content's encoding.
+func ParseReader(r io.Reader, contentType string) (Rss2, error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return Rss2{}, err
+	}
+	return parseBody(body, contentType, "")
+}
 
-	var b bytes.Buffer
-	bwriter := bufio.NewWriter(&b)
-	if _, err = io.Copy(bwriter, resp.Body); err != nil {
-		return result, err
+func parseBody(body []byte, contentType, uri string) (Rss2, error) {
+	content, err := toUTF8(body, contentType)
+	if err != nil {
+		return Rss2{}, errors.Wrap(err, "encoding error")
 	}
 
-	result, err = parseFeedContent(b.Bytes())
+	result, err := parseFeedContent(content)
 	if err != nil {
 		return Rss2{}, errors.Wrap(err, "parsing error")
 	}
 
+	result.FeedURL = uri
 	return result.Normalize()
 }
 
-func atom1ToRss2(a Atom1) Rss2 {
-	r := Rss2{
-		Title:       a.Title,
-		Link:        a.Link.Href,
-		Description: a.Subtitle,
-		PubDate:     a.Updated,
+const (
+	atomNS    = "http://www.w3.org/2005/Atom"
+	rdfNS     = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	contentNS = "http://purl.org/rss/1.0/modules/content/"
+)
+
+// rssVersions are rss versions compatible with Rss2 structure
+var rssVersions = map[string]bool{"0.91": true, "0.92": true, "0.93": true, "0.94": true, "2.0": true}
+
+// parseFeedContent detects feed format by root element, or json object for JSON Feed, and parses it to Rss2.
+// Content is UTF-8 already, transcoded by parseBody.
+func parseFeedContent(content []byte) (Rss2, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte("{")) {
+		return parseJSONFeed(content)
+	}
+	if isHTML(content) {
+		return Rss2{}, ErrHTMLPage
+	}
+
+	root, err := rootElement(content)
+	if err != nil {
+		return Rss2{}, errors.Wrap(err, "can't parse feed content")
+	}
+
+	switch {
+	case root.Name.Local == "rss":
+		return parseRss2(content)
+	case root.Name.Local == "feed" && root.Name.Space == atomNS:
+		return parseAtom(content)
+	case root.Name.Local == "RDF" && root.Name.Space == rdfNS:
+		return parseRss1(content)
 	}
-	r.ItemList = make([]Item, len(a.EntryList))
-	for i, entry := range a.EntryList {
-		r.ItemList[i].Title = entry.Title
-		r.ItemList[i].Link = entry.Link.Href
-		if entry.Content == "" {
-			r.ItemList[i].Description = template.HTML(entry.Summary) // nolint
-		} else {
-			r.ItemList[i].Description = template.HTML(entry.Content) // nolint
+	return Rss2{}, errors.Errorf("unsupported feed format, root element <%s> (%s)", root.Name.Local, root.Name.Space)
+}
+
+// rootElement returns the first start element of xml document
+func rootElement(content []byte) (xml.StartElement, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return xml.StartElement{}, err
+		}
+		if se, ok := token.(xml.StartElement); ok {
+			return se, nil
 		}
 	}
-	return r
 }
 
-const atomErrStr = "expected element type <rss> but have <feed>"
+// rss2Source is rss 2.0 document as parsed, items decoded in the same pass by Item.UnmarshalXML.
+// Root's namespace declarations and channel's extension elements used to complete the items.
+type rss2Source struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Base    string     `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Channel struct {
+		Base          string `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+		Title         string `xml:"title"`
+		Language      string `xml:"language"`
+		Link          string `xml:"link"`
+		Description   string `xml:"description"`
+		PubDate       string `xml:"pubDate"`
+		LastBuildDate string `xml:"lastBuildDate"`
 
-func parseAtom(content []byte) (Rss2, error) {
-	a := Atom1{}
-	err := xml.Unmarshal(content, &a)
-	if err != nil {
-		return Rss2{}, errors.Wrap(err, "can't parse atom1")
+		ITunesImage    ITunesImage      `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd image"`
+		PodcastGUID    string           `xml:"https://podcastindex.org/namespace/1.0 guid"`
+		PodcastFunding []PodcastFunding `xml:"https://podcastindex.org/namespace/1.0 funding"`
+		PodcastPersons []PodcastPerson  `xml:"https://podcastindex.org/namespace/1.0 person"`
+
+		ItemList []Item `xml:"item"`
+	} `xml:"channel"`
+}
+
+// parseRss2 parses RSS 2.0 and compatible RSS 0.9x
+func parseRss2(content []byte) (Rss2, error) {
+	v := rss2Source{}
+	if err := xml.Unmarshal(content, &v); err != nil {
+		return Rss2{}, errors.Wrap(err, "can't parse rss")
+	}
+
+	if !rssVersions[v.Version] {
+		return Rss2{}, errors.New("not RSS 2.0")
+	}
+
+	ch := v.Channel
+	res := Rss2{Version: "2.0", Title: ch.Title, Language: ch.Language, Link: ch.Link, Description: ch.Description,
+		PubDate: ch.PubDate, LastBuildDate: ch.LastBuildDate, ItemList: ch.ItemList}
+
+	prefixes, namespaces := declaredNamespaces(v.Attrs)
+	channelBase := resolveURL(v.Base, ch.Base)
+	res.Link = resolveURL(channelBase, res.Link)
+	for i := range res.ItemList {
+		item := &res.ItemList[i]
+
+		// items of merged feeds lose their channel, so channel's image, persons, funding and guid kept with each item
+		if item.ITunesImage == nil && ch.ITunesImage.Href != "" {
+			item.ITunesImage = &ITunesImage{Href: ch.ITunesImage.Href}
+		}
+		if len(item.PodcastPersons) == 0 {
+			item.PodcastPersons = ch.PodcastPersons
+		}
+		item.PodcastFunding = ch.PodcastFunding
+		item.PodcastFeedGUID = strings.TrimSpace(ch.PodcastGUID)
+
+		for j, ext := range item.Extensions {
+			item.Extensions[j] = ext.withNamespaces(prefixes, namespaces)
+		}
+
+		// urls resolved against xml:base here, the rest of relative urls resolved by Normalize
+		if base := resolveURL(channelBase, item.xmlBase); base != "" {
+			item.resolveURLs(base)
+		}
+		item.xmlBase = ""
+
+		if item.Content != "" {
+			item.Description = item.Content
+		}
 	}
-	return atom1ToRss2(a), nil
+	return res, nil
 }
 
-func parseFeedContent(content []byte) (Rss2, error) {
-	v := Rss2{}
-	err := xml.Unmarshal(content, &v)
-	if err != nil {
-		if err.Error() == atomErrStr {
-			// try Atom 1.0
-			return parseAtom(content)
+// UnmarshalXML decodes rss item in one pass. Elements mapped to item's fields by namespace, so like-named
+// elements of extensions (media:title, itunes:author) don't replace rss ones. Elements of unknown namespaces
+// kept as extensions, unknown elements of itunes, podcast, media and content namespaces dropped.
+func (item *Item) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*item = Item{}
+	for _, a := range start.Attr {
+		if a.Name.Space == xmlNS && a.Name.Local == "base" {
+			item.xmlBase = a.Value
 		}
-		return v, errors.Wrap(err, "can't parse feed content")
 	}
 
-	if v.Version == "2.0" {
-		// RSS 2.0
-		for i := range v.ItemList {
-			if v.ItemList[i].Content != "" {
-				v.ItemList[i].Description = v.ItemList[i].Content
+	media := MediaElements{}
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if err := item.decodeElement(d, t, start.Name.Space, &media); err != nil {
+				return errors.Wrapf(err, "can't decode item's %s", t.Name.Local)
 			}
+		case xml.EndElement:
+			media.apply(item, "")
+			return nil
 		}
-		return v, nil
 	}
+}
 
-	return v, errors.New("not RSS 2.0")
+// decodeElement decodes child element of rss item by its namespace. Rss elements have no namespace,
+// or the item's default one.
+func (item *Item) decodeElement(d *xml.Decoder, el xml.StartElement, rssNS string, media *MediaElements) error {
+	switch el.Name.Space {
+	case ITunesNS:
+		return item.decodeITunes(d, el)
+	case PodcastNS:
+		return item.decodePodcast(d, el)
+	case mediaNS, youTubeNS:
+		return media.decode(d, el)
+	case contentNS:
+		if el.Name.Local == "encoded" {
+			return d.DecodeElement(&item.Content, &el)
+		}
+		return d.Skip()
+	case "", rssNS:
+		if known, err := item.decodeRss(d, el); known || err != nil {
+			return err
+		}
+	}
+
+	ext := Extension{}
+	if err := d.DecodeElement(&ext, &el); err != nil {
+		return err
+	}
+	item.Extensions = append(item.Extensions, ext)
+	return nil
+}
+
+// decodeRss decodes rss element of the item to item's field, returns false for element not mapped to fields
+func (item *Item) decodeRss(d *xml.Decoder, el xml.StartElement) (bool, error) {
+	var dst interface{}
+	switch el.Name.Local {
+	case "title":
+		dst = &item.Title
+	case "link":
+		dst = &item.Link
+	case "description":
+		dst = &item.Description
+	case "encoded":
+		dst = &item.Content
+	case "pubDate":
+		dst = &item.PubDate
+	case "comments":
+		dst = &item.Comments
+	case "enclosure":
+		dst = &item.Enclosure
+	case "guid":
+		dst = &item.GUID
+	case "author":
+		dst = &item.Author
+	case "category":
+		var tag string
+		if err := d.DecodeElement(&tag, &el); err != nil {
+			return true, err
+		}
+		item.Tags = append(item.Tags, tag)
+		return true, nil
+	default:
+		return false, nil
+	}
+	return true, d.DecodeElement(dst, &el)
 }
 
-// Normalize converts to RFC822 = "02 Jan 06 15:04 MST"
+// Normalize converts dates to RFC1123Z, cleans titles and resolves relative urls. Items with missing or unparsable
+// dates get channel's pubDate and marked with DateFallback, items without any known date are left with zero DT,
+// the store sets first seen time for them. lastBuildDate is not used for items, it changes on each regeneration.
 func (rss *Rss2) Normalize() (Rss2, error) {
-	dt, err := rss.normalizeDate(rss.LastBuildDate)
+	itemsDT, _ := rss.normalizeDate(rss.PubDate)
+
+	channelDT, err := rss.normalizeDate(rss.LastBuildDate)
 	if err != nil {
-		dt, err = rss.normalizeDate(rss.PubDate)
+		channelDT, err = itemsDT, nil
+		if itemsDT.IsZero() {
+			err = errors.New("no channel date")
+		}
 	}
-
 	if err == nil {
-		rss.PubDate = dt.Format(time.RFC1123Z)
+		rss.PubDate = channelDT.Format(time.RFC1123Z)
 	}
 
 	for i, item := range rss.ItemList {
-		if dt, err := rss.normalizeDate(item.PubDate); err == nil {
+		dt, err := rss.normalizeDate(item.PubDate)
+		if err != nil && !itemsDT.IsZero() {
+			log.Printf("[DEBUG] use channel pubDate for %s, %v", item.ID(), err)
+			dt, err = itemsDT, nil
+			rss.ItemList[i].DateFallback = true
+		}
+		if err == nil {
 			rss.ItemList[i].DT = dt
 			rss.ItemList[i].PubDate = dt.Format(time.RFC1123Z)
 		}
 		rss.ItemList[i].Title = strings.Replace(item.Title, "\n", "", -1)
 		rss.ItemList[i].Title = strings.TrimSpace(rss.ItemList[i].Title)
+
+		tags := item.Tags
+		rss.ItemList[i].Tags = nil
+		rss.ItemList[i].AddTags(tags...)
 	}
+	rss.resolveURLs()
 	return *rss, nil
 }
 
+// normalizeDate parses date, returns zero time if can't
 func (rss *Rss2) normalizeDate(dt string) (time.Time, error) {
-	if dt == "" {
-		return time.Now(), fmt.Errorf("can't normalize empty pubDate")
+	if strings.TrimSpace(dt) == "" {
+		return time.Time{}, fmt.Errorf("can't normalize empty pubDate")
 	}
-	if ts, err := time.Parse(time.RFC822, dt); err == nil {
-		return ts, nil
-	}
-	if ts, err := time.Parse(time.RFC822Z, dt); err == nil {
-		return ts, nil
-	}
-	if ts, err := time.Parse(time.RFC1123Z, dt); err == nil {
-		return ts, nil
-	}
-	if ts, err := time.Parse(time.RFC1123, dt); err == nil {
-		return ts, nil
-	}
-	if ts, err := time.Parse("2006-01-02 15:04:05 -0700", dt); err == nil {
-		return ts, nil
+	ts, err := parseDate(dt)
+	if err != nil {
+		log.Printf("[DEBUG] can't normalize %s", dt)
+		return time.Time{}, fmt.Errorf("can't normalize %s", dt)
 	}
-	log.Printf("[DEBUG] can't normalize %s", dt)
-	return time.Now(), fmt.Errorf("can't normalize %s", dt)
+	return ts, nil
 }
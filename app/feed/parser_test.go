@@ -111,13 +111,13 @@ func TestNormalizeDate(t *testing.T) {
 		err error
 		out string
 	}{
-		{"", fmt.Errorf("can't normalize empty pubDate"), time.Now().Format(time.RFC822Z)},
+		{"", fmt.Errorf("can't normalize empty pubDate"), time.Time{}.Format(time.RFC822Z)},
 		{"05 Mar 14 22:08 +0400", nil, "05 Mar 14 22:08 +0400"},           // RFC822Z
-		{"05 Mar 14 22:08 MST", nil, "05 Mar 14 22:08 +0000"},             // RFC822
+		{"05 Mar 14 22:08 MST", nil, "05 Mar 14 22:08 -0700"},             // RFC822
 		{"Mon, 02 Jan 2006 15:04:05 -0700", nil, "02 Jan 06 15:04 -0700"}, // RFC1123Z
-		{"Mon, 02 Jan 2006 15:04:05 MST", nil, "02 Jan 06 15:04 +0000"},   // RFC1123
+		{"Mon, 02 Jan 2006 15:04:05 MST", nil, "02 Jan 06 15:04 -0700"},   // RFC1123
 		{"2006-01-02 15:04:05 -0700", nil, "02 Jan 06 15:04 -0700"},
-		{"100500", fmt.Errorf("can't normalize 100500"), time.Now().Format(time.RFC822Z)},
+		{"100500", fmt.Errorf("can't normalize 100500"), time.Time{}.Format(time.RFC822Z)},
 	}
 
 	rss := Rss2{}
@@ -149,7 +149,7 @@ func TestNormalizeIfLastBuildDateAndPubDateInvalidFormat(t *testing.T) {
 			got, err := rss.Normalize()
 
 			assert.NoError(t, err)
-			assert.Equal(t, got.PubDate, "Mon, 02 Jan 2006 15:04:00 +0000")
+			assert.Equal(t, got.PubDate, "Mon, 02 Jan 2006 15:04:00 -0700")
 		})
 	}
 }
@@ -270,3 +270,45 @@ func TestParseFeedContentIfRSSVersionEmptyContent(t *testing.T) {
 	assert.Equal(t, got.ItemList[0].Content, template.HTML("Content"))
 	assert.Equal(t, got.ItemList[0].Description, template.HTML("Content"))
 }
+
+func TestParseRss2ElementsByNamespace(t *testing.T) {
+	rss := `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd" xmlns:content="http://purl.org/rss/1.0/modules/content/"
+	xmlns:dc="http://purl.org/dc/elements/1.1/">
+  <channel>
+    <title>Feed</title>
+    <item>
+      <title>rss title</title>
+      <itunes:title>itunes title</itunes:title>
+      <itunes:author>itunes author</itunes:author>
+      <author>rss author</author>
+      <itunes:keywords>dropped</itunes:keywords>
+      <dc:creator>creator</dc:creator>
+      <description>rss description</description>
+      <content:encoded>content</content:encoded>
+    </item>
+    <item>
+      <guid>2</guid>
+      <dc:subject><title>nested title</title></dc:subject>
+    </item>
+  </channel>
+</rss>`
+
+	got, err := parseFeedContent([]byte(rss))
+	require.NoError(t, err)
+	require.Len(t, got.ItemList, 2)
+
+	item := got.ItemList[0]
+	assert.Equal(t, "rss title", item.Title)
+	assert.Equal(t, "rss author", item.Author)
+	assert.Equal(t, "itunes author", item.ITunesAuthor)
+	assert.Equal(t, template.HTML("content"), item.Description)
+	require.Len(t, item.Extensions, 1, "unknown itunes element dropped")
+	assert.Equal(t, "dc", item.Extensions[0].Prefix)
+	assert.Equal(t, "creator", item.Extensions[0].InnerXML)
+
+	item = got.ItemList[1]
+	assert.Empty(t, item.Title, "not taken from nested element")
+	require.Len(t, item.Extensions, 1)
+	assert.Equal(t, "subject", item.Extensions[0].XMLName.Local)
+}
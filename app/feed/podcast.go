@@ -0,0 +1,88 @@
+package feed
+
+import (
+	"crypto/sha1" // nolint
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// PodcastNS is namespace of Podcasting 2.0 extension
+const PodcastNS = "https://podcastindex.org/namespace/1.0"
+
+// podcastGUIDNamespace is uuid namespace for podcast:guid, defined by Podcasting 2.0 spec
+var podcastGUIDNamespace = [16]byte{0xea, 0xd4, 0xc2, 0x36, 0xbf, 0x58, 0x58, 0xc6, 0xa2, 0xc6, 0xa6, 0xb2, 0x8d, 0x12, 0x8c, 0xb6}
+
+// PodcastTranscript is podcast:transcript element of the item
+type PodcastTranscript struct {
+	URL      string `xml:"url,attr"`
+	Type     string `xml:"type,attr"`
+	Language string `xml:"language,attr,omitempty"`
+	Rel      string `xml:"rel,attr,omitempty"`
+}
+
+// PodcastChapters is podcast:chapters element of the item
+type PodcastChapters struct {
+	URL  string `xml:"url,attr"`
+	Type string `xml:"type,attr"`
+}
+
+// PodcastPerson is podcast:person element of the item or channel
+type PodcastPerson struct {
+	Name  string `xml:",chardata"`
+	Role  string `xml:"role,attr,omitempty"`
+	Group string `xml:"group,attr,omitempty"`
+	Img   string `xml:"img,attr,omitempty"`
+	Href  string `xml:"href,attr,omitempty"`
+}
+
+// PodcastFunding is podcast:funding element of the channel
+type PodcastFunding struct {
+	URL  string `xml:"url,attr"`
+	Text string `xml:",chardata"`
+}
+
+// decodePodcast decodes Podcasting 2.0 element of rss item to item's field, unknown elements skipped
+func (item *Item) decodePodcast(d *xml.Decoder, el xml.StartElement) error {
+	switch el.Name.Local {
+	case "transcript":
+		t := PodcastTranscript{}
+		if err := d.DecodeElement(&t, &el); err != nil {
+			return err
+		}
+		item.PodcastTranscripts = append(item.PodcastTranscripts, t)
+		return nil
+	case "chapters":
+		c := PodcastChapters{}
+		if err := d.DecodeElement(&c, &el); err != nil {
+			return err
+		}
+		item.PodcastChapters = &c
+		return nil
+	case "person":
+		p := PodcastPerson{}
+		if err := d.DecodeElement(&p, &el); err != nil {
+			return err
+		}
+		item.PodcastPersons = append(item.PodcastPersons, p)
+		return nil
+	}
+	return d.Skip()
+}
+
+// PodcastGUID makes podcast:guid for the feed url, uuid v5 of url without scheme and trailing slashes
+func PodcastGUID(feedURL string) string {
+	name := feedURL
+	if i := strings.Index(name, "://"); i >= 0 {
+		name = name[i+3:]
+	}
+	name = strings.TrimRight(name, "/")
+
+	h := sha1.New() // nolint
+	_, _ = h.Write(podcastGUIDNamespace[:])
+	_, _ = h.Write([]byte(name))
+	u := h.Sum(nil)[:16]
+	u[6] = (u[6] & 0x0f) | 0x50 // version 5
+	u[8] = (u[8] & 0x3f) | 0x80 // rfc 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
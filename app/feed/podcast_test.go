@@ -0,0 +1,79 @@
+package feed
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePodcast(t *testing.T) {
+	rss := `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:podcast="https://podcastindex.org/namespace/1.0">
+<channel>
+	<title>Podcast</title>
+	<podcast:guid>917393e3-1b1e-5cef-ace4-edaa54e1f810</podcast:guid>
+	<podcast:funding url="https://example.com/donate">Support the show</podcast:funding>
+	<podcast:person role="host" img="https://example.com/host.jpg">Host Name</podcast:person>
+	<item>
+		<title>Episode 2</title>
+		<guid>e2</guid>
+		<podcast:transcript url="https://example.com/e2.vtt" type="text/vtt"/>
+		<podcast:transcript url="https://example.com/e2.srt" type="application/srt" language="en" rel="captions"/>
+		<podcast:chapters url="https://example.com/e2.json" type="application/json+chapters"/>
+		<podcast:person role="guest" href="https://example.com/guest">Guest Name</podcast:person>
+	</item>
+	<item>
+		<title>Episode 1</title>
+		<guid>e1</guid>
+	</item>
+</channel>
+</rss>`
+
+	got, err := parseFeedContent([]byte(rss))
+	require.NoError(t, err)
+	require.Len(t, got.ItemList, 2)
+
+	e2 := got.ItemList[0]
+	assert.Equal(t, []PodcastTranscript{
+		{URL: "https://example.com/e2.vtt", Type: "text/vtt"},
+		{URL: "https://example.com/e2.srt", Type: "application/srt", Language: "en", Rel: "captions"},
+	}, e2.PodcastTranscripts)
+	assert.Equal(t, &PodcastChapters{URL: "https://example.com/e2.json", Type: "application/json+chapters"}, e2.PodcastChapters)
+	assert.Equal(t, []PodcastPerson{{Name: "Guest Name", Role: "guest", Href: "https://example.com/guest"}}, e2.PodcastPersons)
+	assert.Equal(t, []PodcastFunding{{URL: "https://example.com/donate", Text: "Support the show"}}, e2.PodcastFunding)
+	assert.Equal(t, "917393e3-1b1e-5cef-ace4-edaa54e1f810", e2.PodcastFeedGUID)
+	assert.Equal(t, "e2", e2.GUID)
+
+	e1 := got.ItemList[1]
+	assert.Empty(t, e1.PodcastTranscripts)
+	assert.Nil(t, e1.PodcastChapters)
+	assert.Equal(t, []PodcastPerson{{Name: "Host Name", Role: "host", Img: "https://example.com/host.jpg"}},
+		e1.PodcastPersons, "channel's persons")
+
+	// republished and parsed back
+	got.PodcastNS = PodcastNS
+	got.PodcastGUID = "guid"
+	got.PodcastFunding = e2.PodcastFunding
+	b, err := xml.Marshal(&got)
+	require.NoError(t, err)
+	res := string(b)
+	assert.Contains(t, res, `xmlns:podcast="https://podcastindex.org/namespace/1.0"`)
+	assert.Contains(t, res, `<podcast:guid>guid</podcast:guid><podcast:funding url="https://example.com/donate">Support the show</podcast:funding>`)
+	assert.Contains(t, res, `<podcast:transcript url="https://example.com/e2.vtt" type="text/vtt"></podcast:transcript>`)
+	assert.Contains(t, res, `<podcast:chapters url="https://example.com/e2.json" type="application/json+chapters"></podcast:chapters>`)
+	assert.Contains(t, res, `<podcast:person role="guest" href="https://example.com/guest">Guest Name</podcast:person>`)
+
+	back, err := parseFeedContent(b)
+	require.NoError(t, err)
+	assert.Equal(t, got.ItemList[0].PodcastTranscripts, back.ItemList[0].PodcastTranscripts)
+	assert.Equal(t, got.ItemList[0].PodcastChapters, back.ItemList[0].PodcastChapters)
+	assert.Equal(t, got.ItemList[1].PodcastPersons, back.ItemList[1].PodcastPersons)
+}
+
+func TestPodcastGUID(t *testing.T) {
+	// example from podcast namespace spec
+	assert.Equal(t, "917393e3-1b1e-5cef-ace4-edaa54e1f810", PodcastGUID("https://mp3s.nashownotes.com/pc20rss.xml"))
+	assert.Equal(t, "917393e3-1b1e-5cef-ace4-edaa54e1f810", PodcastGUID("http://mp3s.nashownotes.com/pc20rss.xml/"))
+}
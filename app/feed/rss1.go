@@ -0,0 +1,82 @@
+package feed
+
+import (
+	"encoding/xml"
+	"html/template"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Rss1 is RDF based RSS 1.0 feed, covers RSS 0.90 as well
+type Rss1 struct {
+	XMLName xml.Name `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# RDF"`
+	Channel struct {
+		Title       string `xml:"title"`
+		Link        string `xml:"link"`
+		Description string `xml:"description"`
+		Language    string `xml:"http://purl.org/dc/elements/1.1/ language"`
+		Date        string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	} `xml:"channel"`
+	ItemList []Rss1Item `xml:"item"`
+}
+
+// Rss1Item is item of RSS 1.0 feed
+type Rss1Item struct {
+	About       string   `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# about,attr"`
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Description string   `xml:"description"`
+	Content     string   `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	Date        string   `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Creator     string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Subjects    []string `xml:"http://purl.org/dc/elements/1.1/ subject"`
+	Enclosure   struct {
+		Resource string `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# resource,attr"`
+		Type     string `xml:"http://purl.oclc.org/net/rss_2.0/enc# type,attr"`
+		Length   int    `xml:"http://purl.oclc.org/net/rss_2.0/enc# length,attr"`
+	} `xml:"http://purl.oclc.org/net/rss_2.0/enc# enclosure"`
+}
+
+func parseRss1(content []byte) (Rss2, error) {
+	v := Rss1{}
+	if err := xml.Unmarshal(content, &v); err != nil {
+		return Rss2{}, errors.Wrap(err, "can't parse rss1")
+	}
+	return rss1ToRss2(v), nil
+}
+
+func rss1ToRss2(v Rss1) Rss2 {
+	r := Rss2{
+		Version:     "2.0",
+		Title:       strings.TrimSpace(v.Channel.Title),
+		Link:        strings.TrimSpace(v.Channel.Link),
+		Description: strings.TrimSpace(v.Channel.Description),
+		Language:    strings.TrimSpace(v.Channel.Language),
+		PubDate:     pubDate(v.Channel.Date),
+	}
+
+	r.ItemList = make([]Item, len(v.ItemList))
+	for i, entry := range v.ItemList {
+		item := Item{
+			Title:       strings.TrimSpace(entry.Title),
+			Link:        strings.TrimSpace(entry.Link),
+			GUID:        entry.About,
+			Description: template.HTML(strings.TrimSpace(entry.Description)), // nolint
+			PubDate:     pubDate(entry.Date),
+			Author:      strings.TrimSpace(entry.Creator),
+			Tags:        entry.Subjects,
+		}
+		if entry.Content != "" {
+			item.Description = template.HTML(strings.TrimSpace(entry.Content)) // nolint
+		}
+		if item.GUID == "" {
+			item.GUID = item.Link
+		}
+		if entry.Enclosure.Resource != "" {
+			item.Enclosure = Enclosure{URL: entry.Enclosure.Resource, Type: entry.Enclosure.Type, Length: entry.Enclosure.Length}
+		}
+		r.ItemList[i] = item
+	}
+	return r
+}
@@ -0,0 +1,115 @@
+package feed
+
+import (
+	"html/template"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFeedContentRss1(t *testing.T) {
+	rdf := `<?xml version="1.0" encoding="utf-8"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/"
+  xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:content="http://purl.org/rss/1.0/modules/content/"
+  xmlns:enc="http://purl.oclc.org/net/rss_2.0/enc#">
+  <channel rdf:about="http://radio.example.com/">
+    <title>Radio</title>
+    <link>http://radio.example.com/</link>
+    <description>Old radio feed</description>
+    <dc:language>ru</dc:language>
+    <dc:date>2021-07-10T18:30:02+03:00</dc:date>
+    <items><rdf:Seq><rdf:li rdf:resource="http://radio.example.com/1"/></rdf:Seq></items>
+  </channel>
+  <item rdf:about="http://radio.example.com/1">
+    <title>Show 1</title>
+    <link>http://radio.example.com/1</link>
+    <description>Description 1</description>
+    <content:encoded><![CDATA[<p>Content 1</p>]]></content:encoded>
+    <dc:date>2021-07-09</dc:date>
+    <dc:creator>Host</dc:creator>
+    <enc:enclosure rdf:resource="http://radio.example.com/1.mp3" enc:type="audio/mpeg" enc:length="1234"/>
+  </item>
+  <item>
+    <title>Show 2</title>
+    <link>http://radio.example.com/2</link>
+  </item>
+</rdf:RDF>`
+
+	got, err := parseFeedContent([]byte(rdf))
+	require.NoError(t, err)
+	assert.Equal(t, "Radio", got.Title)
+	assert.Equal(t, "http://radio.example.com/", got.Link)
+	assert.Equal(t, "Old radio feed", got.Description)
+	assert.Equal(t, "ru", got.Language)
+	assert.Equal(t, "Sat, 10 Jul 2021 18:30:02 +0300", got.PubDate)
+
+	require.Len(t, got.ItemList, 2)
+	item := got.ItemList[0]
+	assert.Equal(t, "Show 1", item.Title)
+	assert.Equal(t, "http://radio.example.com/1", item.GUID)
+	assert.Equal(t, template.HTML("<p>Content 1</p>"), item.Description)
+	assert.Equal(t, "Fri, 09 Jul 2021 00:00:00 +0000", item.PubDate)
+	assert.Equal(t, "Host", item.Author)
+	assert.Equal(t, Enclosure{URL: "http://radio.example.com/1.mp3", Type: "audio/mpeg", Length: 1234}, item.Enclosure)
+	assert.Equal(t, "http://radio.example.com/2", got.ItemList[1].GUID, "link used as guid")
+}
+
+func TestParseFeedContentRss090(t *testing.T) {
+	rdf := `<?xml version="1.0"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://my.netscape.com/rdf/simple/0.9/">
+  <channel>
+    <title>Mozilla Dot Org</title>
+    <link>http://www.mozilla.org</link>
+    <description>the Mozilla Organization web site</description>
+  </channel>
+  <item>
+    <title>New Status Updates</title>
+    <link>http://www.mozilla.org/status/</link>
+  </item>
+</rdf:RDF>`
+
+	got, err := parseFeedContent([]byte(rdf))
+	require.NoError(t, err)
+	assert.Equal(t, "Mozilla Dot Org", got.Title)
+	require.Len(t, got.ItemList, 1)
+	assert.Equal(t, "New Status Updates", got.ItemList[0].Title)
+	assert.Equal(t, "http://www.mozilla.org/status/", got.ItemList[0].Link)
+}
+
+func TestParseFeedContentRss09x(t *testing.T) {
+	for _, version := range []string{"0.91", "0.92"} {
+		rss := `<?xml version="1.0"?>
+<rss version="` + version + `">
+  <channel>
+    <title>Echo</title>
+    <link>http://echo.example.com</link>
+    <description>Radio</description>
+    <language>ru</language>
+    <item>
+      <title>Programme</title>
+      <link>http://echo.example.com/1</link>
+      <description>Desc</description>
+      <enclosure url="http://echo.example.com/1.mp3" length="10" type="audio/mpeg"/>
+    </item>
+  </channel>
+</rss>`
+		got, err := parseFeedContent([]byte(rss))
+		require.NoError(t, err, version)
+		assert.Equal(t, "2.0", got.Version)
+		assert.Equal(t, "Echo", got.Title)
+		require.Len(t, got.ItemList, 1)
+		assert.Equal(t, "http://echo.example.com/1.mp3", got.ItemList[0].Enclosure.URL)
+	}
+}
+
+func TestParseFeedContentUnsupported(t *testing.T) {
+	_, err := parseFeedContent([]byte(`<?xml version="1.0"?><html><body>not a feed</body></html>`))
+	assert.Equal(t, ErrHTMLPage, err)
+
+	_, err = parseFeedContent([]byte(`<?xml version="1.0"?><opml version="2.0"><body></body></opml>`))
+	assert.EqualError(t, err, "unsupported feed format, root element <opml> ()")
+
+	_, err = parseFeedContent([]byte(`<feed xmlns="http://example.com/not-atom"></feed>`))
+	assert.EqualError(t, err, "unsupported feed format, root element <feed> (http://example.com/not-atom)")
+}
@@ -0,0 +1,199 @@
+package feed
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/pkg/errors"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+// Selectors define css selectors of episodes listed on html page. Item selects episode's container,
+// the rest are matched inside of it. Link and Audio default to the first link and audio found in the container.
+type Selectors struct {
+	Item        string `yaml:"item"`
+	Title       string `yaml:"title"`
+	Link        string `yaml:"link"`
+	Date        string `yaml:"date"`
+	Audio       string `yaml:"audio"`
+	Description string `yaml:"description"`
+}
+
+const (
+	defaultLinkSelector  = "a[href]"
+	defaultAudioSelector = "audio[src], audio source[src], a[href$='.mp3'], a[href$='.m4a']"
+)
+
+// audioTypes maps extensions of audio files to media types, mime package knows none of them without system tables
+var audioTypes = map[string]string{
+	".mp3": "audio/mpeg", ".m4a": "audio/mp4", ".aac": "audio/aac", ".ogg": "audio/ogg", ".oga": "audio/ogg",
+	".opus": "audio/opus", ".wav": "audio/wav", ".flac": "audio/flac", ".mp4": "video/mp4",
+}
+
+// compiledSelectors are Selectors ready to match, nil for unset optional ones
+type compiledSelectors struct {
+	item, title, link, date, audio, description cascadia.Selector
+}
+
+// Validate checks selectors are set and valid
+func (s Selectors) Validate() error {
+	_, err := s.compile()
+	return err
+}
+
+func (s Selectors) compile() (res compiledSelectors, err error) {
+	if strings.TrimSpace(s.Item) == "" {
+		return res, errors.New("empty item selector")
+	}
+	if strings.TrimSpace(s.Title) == "" {
+		return res, errors.New("empty title selector")
+	}
+
+	link, audio := s.Link, s.Audio
+	if strings.TrimSpace(link) == "" {
+		link = defaultLinkSelector
+	}
+	if strings.TrimSpace(audio) == "" {
+		audio = defaultAudioSelector
+	}
+
+	for _, sel := range []struct {
+		name string
+		src  string
+		dst  *cascadia.Selector
+	}{
+		{"item", s.Item, &res.item}, {"title", s.Title, &res.title}, {"link", link, &res.link},
+		{"date", s.Date, &res.date}, {"audio", audio, &res.audio}, {"description", s.Description, &res.description},
+	} {
+		if strings.TrimSpace(sel.src) == "" {
+			continue
+		}
+		if *sel.dst, err = cascadia.Compile(sel.src); err != nil {
+			return res, errors.Wrapf(err, "invalid %s selector %q", sel.name, sel.src)
+		}
+	}
+	return res, nil
+}
+
+// Scrape gets html page listing episodes and makes normalized Rss2 of them with given selectors.
+// opts used the same way as by ParseWithOptions.
+func Scrape(ctx context.Context, uri string, sel Selectors, opts ParseOpts) (Rss2, error) {
+	resp, err := fetchChanged(ctx, uri, opts)
+	if err != nil {
+		return Rss2{}, err
+	}
+	return scrapeBody(resp.Body, resp.Header.Get("Content-Type"), uri, sel)
+}
+
+func scrapeBody(body []byte, contentType, uri string, sel Selectors) (Rss2, error) {
+	cs, err := sel.compile()
+	if err != nil {
+		return Rss2{}, err
+	}
+
+	r, err := charset.NewReader(bytes.NewReader(body), contentType)
+	if err != nil {
+		return Rss2{}, errors.Wrap(err, "encoding error")
+	}
+	doc, err := html.Parse(r)
+	if err != nil {
+		return Rss2{}, errors.Wrap(err, "can't parse html")
+	}
+
+	result := Rss2{Version: "2.0", Link: uri, FeedURL: uri}
+	if t := cascadia.MustCompile("head title").MatchFirst(doc); t != nil {
+		result.Title = nodeText(t)
+	}
+
+	for _, n := range cs.item.MatchAll(doc) {
+		item := Item{Title: nodeText(cs.title.MatchFirst(n))}
+		if link := cs.link.MatchFirst(n); link != nil {
+			item.Link = nodeValue(link, "href")
+		}
+		if cs.date != nil {
+			item.PubDate = nodeValue(cs.date.MatchFirst(n), "datetime", "content")
+		}
+		if audio := cs.audio.MatchFirst(n); audio != nil {
+			audioURL := nodeValue(audio, "src", "href")
+			item.Enclosure = Enclosure{URL: audioURL, Type: audioType(audio, audioURL)}
+		}
+		if cs.description != nil {
+			item.Description = innerHTML(cs.description.MatchFirst(n))
+		}
+		if item.Title == "" && item.Link == "" && item.Enclosure.URL == "" {
+			continue
+		}
+		result.ItemList = append(result.ItemList, item)
+	}
+
+	if len(result.ItemList) == 0 {
+		return Rss2{}, errors.Errorf("no items matched %q", sel.Item)
+	}
+	return result.Normalize()
+}
+
+// nodeText returns text of the node with collapsed whitespaces, empty for nil node
+func nodeText(n *html.Node) string {
+	if n == nil {
+		return ""
+	}
+	var buf strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			buf.WriteString(n.Data)
+			buf.WriteString(" ")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
+
+// nodeValue returns the first non-empty of given attributes of the node, its text if none set
+func nodeValue(n *html.Node, keys ...string) string {
+	if n == nil {
+		return ""
+	}
+	for _, key := range keys {
+		if v := attr(n.Attr, key); v != "" {
+			return v
+		}
+	}
+	return nodeText(n)
+}
+
+// innerHTML renders children of the node
+func innerHTML(n *html.Node) template.HTML {
+	if n == nil {
+		return ""
+	}
+	var buf bytes.Buffer
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(&buf, c); err != nil {
+			return ""
+		}
+	}
+	return template.HTML(strings.TrimSpace(buf.String())) // nolint
+}
+
+// audioType returns media type of audio element or link, detected by url's extension if not set, audio/mpeg by default
+func audioType(n *html.Node, audioURL string) string {
+	if t := mediaType(attr(n.Attr, "type")); t != "" {
+		return t
+	}
+	if u, err := url.Parse(audioURL); err == nil {
+		if t, ok := audioTypes[strings.ToLower(path.Ext(u.Path))]; ok {
+			return t
+		}
+	}
+	return "audio/mpeg"
+}
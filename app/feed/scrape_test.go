@@ -0,0 +1,82 @@
+package feed
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScrape(t *testing.T) {
+	page, err := ioutil.ReadFile("testdata/episodes.html")
+	require.NoError(t, err)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write(page)
+	}))
+	defer ts.Close()
+
+	sel := Selectors{Item: "li.episode", Title: "h2", Date: "time, .date", Audio: "audio source, a.download", Description: ".notes"}
+	rss, err := Scrape(context.Background(), ts.URL+"/shows/radio", sel, ParseOpts{})
+	require.NoError(t, err)
+
+	assert.Equal(t, "Radio Show Episodes", rss.Title)
+	assert.Equal(t, ts.URL+"/shows/radio", rss.Link)
+	require.Len(t, rss.ItemList, 3)
+
+	ep3 := rss.ItemList[0]
+	assert.Equal(t, "Episode 3: the last one", ep3.Title)
+	assert.Equal(t, ts.URL+"/episodes/3", ep3.Link)
+	assert.Equal(t, time.Date(2021, 7, 10, 15, 30, 0, 0, time.UTC), ep3.DT.UTC())
+	assert.Equal(t, Enclosure{URL: ts.URL + "/media/ep3.m4a", Type: "audio/x-m4a"}, ep3.Enclosure)
+	assert.Equal(t, `<p>Notes with <a href="`+ts.URL+`/episodes/links.html">links</a></p>`, string(ep3.Description))
+
+	ep2 := rss.ItemList[1]
+	assert.Equal(t, "Episode 2", ep2.Title)
+	assert.Equal(t, "https://example.com/episodes/2", ep2.Link)
+	assert.Equal(t, time.Date(2021, 7, 10, 18, 30, 0, 0, time.UTC), ep2.DT.UTC())
+	assert.Equal(t, Enclosure{URL: ts.URL + "/media/ep2.mp3?src=site", Type: "audio/mpeg"}, ep2.Enclosure)
+	assert.Equal(t, "", string(ep2.Description))
+
+	ep1 := rss.ItemList[2]
+	assert.Equal(t, "Episode 1", ep1.Title)
+	assert.Equal(t, "", ep1.Link)
+	assert.True(t, ep1.DT.IsZero())
+	assert.Equal(t, Enclosure{}, ep1.Enclosure)
+
+	t.Run("not modified", func(t *testing.T) {
+		v := &Validators{}
+		_, err := Scrape(context.Background(), ts.URL, sel, ParseOpts{Validators: v})
+		require.NoError(t, err)
+		_, err = Scrape(context.Background(), ts.URL, sel, ParseOpts{Validators: v})
+		assert.Equal(t, ErrNotModified, err)
+	})
+
+	t.Run("default selectors", func(t *testing.T) {
+		rss, err := Scrape(context.Background(), ts.URL, Selectors{Item: "li.episode", Title: "h2"}, ParseOpts{})
+		require.NoError(t, err)
+		require.Len(t, rss.ItemList, 3)
+		assert.Equal(t, ts.URL+"/media/ep3.m4a", rss.ItemList[0].Enclosure.URL)
+		assert.Equal(t, ts.URL+"/episodes/3", rss.ItemList[0].Link)
+		assert.Equal(t, "", rss.ItemList[1].Enclosure.URL, "link with query is not matched by default")
+		assert.Equal(t, "", rss.ItemList[0].PubDate)
+	})
+
+	t.Run("no items", func(t *testing.T) {
+		_, err := Scrape(context.Background(), ts.URL, Selectors{Item: ".show", Title: "h2"}, ParseOpts{})
+		assert.EqualError(t, err, `no items matched ".show"`)
+	})
+}
+
+func TestSelectorsValidate(t *testing.T) {
+	assert.EqualError(t, Selectors{Title: "h2"}.Validate(), "empty item selector")
+	assert.EqualError(t, Selectors{Item: "li"}.Validate(), "empty title selector")
+	assert.EqualError(t, Selectors{Item: "li", Title: "h2", Audio: "a[href"}.Validate(),
+		`invalid audio selector "a[href": unexpected EOF in attribute selector`)
+	assert.NoError(t, Selectors{Item: "li", Title: "h2"}.Validate())
+}
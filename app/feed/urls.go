@@ -0,0 +1,148 @@
+package feed
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// xmlNS is namespace of xml: attributes, like xml:base
+const xmlNS = "http://www.w3.org/XML/1998/namespace"
+
+// urlAttrs are html attributes with urls, resolved in descriptions
+var urlAttrs = map[string]bool{"href": true, "src": true, "poster": true, "srcset": true}
+
+// resolveURLs resolves relative urls of the channel link and items against the channel link or FeedURL
+func (rss *Rss2) resolveURLs() {
+	rss.Link = resolveURL(rss.FeedURL, rss.Link)
+	base := rss.Link
+	if !isAbsURL(base) {
+		base = rss.FeedURL
+	}
+	for i := range rss.ItemList {
+		rss.ItemList[i].resolveURLs(base)
+	}
+}
+
+// resolveURLs resolves relative urls of the item against base, and urls in description and content
+// against the item's link, the page they come from
+func (item *Item) resolveURLs(base string) {
+	item.Link = resolveURL(base, item.Link)
+	item.Comments = resolveURL(base, item.Comments)
+	item.Enclosure.URL = resolveURL(base, item.Enclosure.URL)
+	item.Thumbnail = resolveURL(base, item.Thumbnail)
+	if item.ITunesImage != nil {
+		item.ITunesImage.Href = resolveURL(base, item.ITunesImage.Href)
+	}
+	for i := range item.PodcastTranscripts {
+		item.PodcastTranscripts[i].URL = resolveURL(base, item.PodcastTranscripts[i].URL)
+	}
+	if item.PodcastChapters != nil {
+		item.PodcastChapters.URL = resolveURL(base, item.PodcastChapters.URL)
+	}
+	for i := range item.PodcastPersons {
+		item.PodcastPersons[i].Img = resolveURL(base, item.PodcastPersons[i].Img)
+		item.PodcastPersons[i].Href = resolveURL(base, item.PodcastPersons[i].Href)
+	}
+	for i := range item.PodcastFunding {
+		item.PodcastFunding[i].URL = resolveURL(base, item.PodcastFunding[i].URL)
+	}
+
+	pageURL := item.Link
+	if !isAbsURL(pageURL) {
+		pageURL = base
+	}
+	item.Description = resolveHTML(pageURL, item.Description)
+	item.Content = resolveHTML(pageURL, item.Content)
+}
+
+// resolveURL resolves possibly relative ref against base, returns ref as is if it is absolute or can't be resolved
+func resolveURL(base, ref string) string {
+	if base == "" || ref == "" {
+		return ref
+	}
+	r, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil || r.IsAbs() {
+		return ref
+	}
+	b, err := url.Parse(strings.TrimSpace(base))
+	if err != nil {
+		return ref
+	}
+	return b.ResolveReference(r).String()
+}
+
+func isAbsURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.IsAbs()
+}
+
+// resolveHTML rewrites relative urls of links, images and media in html fragment, returns it as is if nothing changed
+func resolveHTML(base string, h template.HTML) template.HTML {
+	if base == "" || !strings.Contains(string(h), "<") {
+		return h
+	}
+
+	var buf bytes.Buffer
+	changed := false
+	z := html.NewTokenizer(strings.NewReader(string(h)))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if z.Err() != io.EOF {
+				return h
+			}
+			break
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			buf.Write(z.Raw())
+			continue
+		}
+
+		raw := string(z.Raw()) // copied, as Token lowercases raw buffer in place
+		tok := z.Token()
+		tagChanged := false
+		for i, a := range tok.Attr {
+			if !urlAttrs[a.Key] {
+				continue
+			}
+			val := resolveURL(base, a.Val)
+			if a.Key == "srcset" {
+				val = resolveSrcset(base, a.Val)
+			}
+			if val != a.Val {
+				tok.Attr[i].Val = val
+				tagChanged = true
+			}
+		}
+		if !tagChanged {
+			buf.WriteString(raw)
+			continue
+		}
+		changed = true
+		buf.WriteString(tok.String())
+	}
+
+	if !changed {
+		return h
+	}
+	return template.HTML(buf.String()) // nolint
+}
+
+// resolveSrcset resolves urls of srcset attribute, list of "url [descriptor]"
+func resolveSrcset(base, srcset string) string {
+	candidates := strings.Split(srcset, ",")
+	for i, c := range candidates {
+		fields := strings.Fields(c)
+		if len(fields) == 0 {
+			continue
+		}
+		fields[0] = resolveURL(base, fields[0])
+		candidates[i] = strings.Join(fields, " ")
+	}
+	return strings.Join(candidates, ", ")
+}
@@ -0,0 +1,88 @@
+package feed
+
+import (
+	"html/template"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveURL(t *testing.T) {
+	tbl := []struct {
+		base, ref, res string
+	}{
+		{"https://example.com/feed/rss.xml", "1.mp3", "https://example.com/feed/1.mp3"},
+		{"https://example.com/feed/rss.xml", "/media/1.mp3", "https://example.com/media/1.mp3"},
+		{"https://example.com/feed/rss.xml", "//cdn.example.com/1.mp3", "https://cdn.example.com/1.mp3"},
+		{"https://example.com/feed/rss.xml", "http://other.com/a/../1.mp3", "http://other.com/a/../1.mp3"},
+		{"https://example.com/feed/rss.xml", "mailto:info@example.com", "mailto:info@example.com"},
+		{"http://example.com/a/", "rel", "http://example.com/a/rel"},
+		{"http://example.com/a/", "http://other.com/x", "http://other.com/x"},
+		{"", "/rel", "/rel"},
+		{"http://example.com/a/", "", ""},
+	}
+	for i, tt := range tbl {
+		assert.Equal(t, tt.res, resolveURL(tt.base, tt.ref), "#%d", i)
+	}
+}
+
+func TestResolveHTML(t *testing.T) {
+	tbl := []struct {
+		inp, res string
+	}{
+		{`no tags`, `no tags`},
+		{`<p>text &amp; <a href="/page">link</a></p>`, `<p>text &amp; <a href="https://example.com/page">link</a></p>`},
+		{`<IMG SRC="img.png" alt="x"/>`, `<img src="https://example.com/posts/img.png" alt="x"/>`},
+		{`<img srcset="a.png 1x, /b.png 2x">`, `<img srcset="https://example.com/posts/a.png 1x, https://example.com/b.png 2x">`},
+		{`<a href="https://other.com/x">abs</a><br>`, `<a href="https://other.com/x">abs</a><br>`},
+		{`<video poster="p.jpg"><source src="v.mp4"></video>`,
+			`<video poster="https://example.com/posts/p.jpg"><source src="https://example.com/posts/v.mp4"></video>`},
+	}
+	for i, tt := range tbl {
+		assert.Equal(t, template.HTML(tt.res), resolveHTML("https://example.com/posts/1", template.HTML(tt.inp)), "#%d", i)
+	}
+}
+
+func TestParseRelativeURLs(t *testing.T) {
+	rss := `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd">
+<channel>
+	<title>Feed</title>
+	<link>/podcast/</link>
+	<item>
+		<title>Item 1</title>
+		<guid>1</guid>
+		<link>episodes/1</link>
+		<description>&lt;a href="notes.html"&gt;notes&lt;/a&gt; &lt;img src="/img/1.png"&gt;</description>
+		<enclosure url="/media/1.mp3" type="audio/mpeg" length="100"/>
+		<itunes:image href="cover.png"/>
+	</item>
+	<item xml:base="https://cdn.example.com/files/">
+		<title>Item 2</title>
+		<guid>2</guid>
+		<enclosure url="2.mp3" type="audio/mpeg" length="100"/>
+	</item>
+</channel>
+</rss>`
+
+	got, err := parseBody([]byte(rss), "", "https://example.com/feeds/rss.xml")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/podcast/", got.Link, "resolved against fetch url")
+	require.Len(t, got.ItemList, 2)
+
+	item := got.ItemList[0]
+	assert.Equal(t, "https://example.com/podcast/episodes/1", item.Link, "resolved against channel link")
+	assert.Equal(t, "https://example.com/media/1.mp3", item.Enclosure.URL)
+	assert.Equal(t, "https://example.com/podcast/cover.png", item.ITunesImage.Href)
+	assert.Equal(t, template.HTML(`<a href="https://example.com/podcast/episodes/notes.html">notes</a> `+
+		`<img src="https://example.com/img/1.png">`), item.Description, "resolved against item link")
+
+	item = got.ItemList[1]
+	assert.Equal(t, "https://cdn.example.com/files/2.mp3", item.Enclosure.URL, "resolved against xml:base")
+
+	// no fetch url and relative channel link, relative urls kept
+	got, err = parseBody([]byte(rss), "", "")
+	require.NoError(t, err)
+	assert.Equal(t, "/media/1.mp3", got.ItemList[0].Enclosure.URL)
+}
@@ -0,0 +1,203 @@
+package feed
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/pkg/errors"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+// Page is normalized text of watched web page, a line per block element with collapsed whitespace
+type Page struct {
+	Title string
+	Text  string
+}
+
+// maxDiffLines limits changed lines shown by TextDiff
+const maxDiffLines = 100
+
+// skipped elements have no visible text
+var watchSkip = map[atom.Atom]bool{
+	atom.Head: true, atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true, atom.Svg: true,
+}
+
+// watchBlocks are elements starting a new line of text
+var watchBlocks = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true, atom.Blockquote: true, atom.Br: true, atom.Dd: true,
+	atom.Div: true, atom.Dl: true, atom.Dt: true, atom.Figcaption: true, atom.Footer: true, atom.Form: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true, atom.Header: true,
+	atom.Hr: true, atom.Li: true, atom.Main: true, atom.Nav: true, atom.Ol: true, atom.P: true, atom.Pre: true,
+	atom.Section: true, atom.Table: true, atom.Td: true, atom.Th: true, atom.Tr: true, atom.Ul: true,
+}
+
+// ValidateSelector checks css selector of watched part of page, empty one is valid
+func ValidateSelector(selector string) error {
+	if strings.TrimSpace(selector) == "" {
+		return nil
+	}
+	if _, err := cascadia.Compile(selector); err != nil {
+		return errors.Wrapf(err, "invalid selector %q", selector)
+	}
+	return nil
+}
+
+// Watch gets web page and returns its normalized text, of elements matching css selector if set.
+// opts used the same way as by ParseWithOptions, ErrNotModified returned if page not changed.
+func Watch(ctx context.Context, uri, selector string, opts ParseOpts) (Page, error) {
+	resp, err := fetchChanged(ctx, uri, opts)
+	if err != nil {
+		return Page{}, err
+	}
+	return watchBody(resp.Body, resp.Header.Get("Content-Type"), selector)
+}
+
+func watchBody(body []byte, contentType, selector string) (Page, error) {
+	r, err := charset.NewReader(bytes.NewReader(body), contentType)
+	if err != nil {
+		return Page{}, errors.Wrap(err, "encoding error")
+	}
+	doc, err := html.Parse(r)
+	if err != nil {
+		return Page{}, errors.Wrap(err, "can't parse html")
+	}
+
+	res := Page{}
+	if t := cascadia.MustCompile("head title").MatchFirst(doc); t != nil {
+		res.Title = nodeText(t)
+	}
+
+	nodes := []*html.Node{doc}
+	if strings.TrimSpace(selector) != "" {
+		sel, err := cascadia.Compile(selector)
+		if err != nil {
+			return Page{}, errors.Wrapf(err, "invalid selector %q", selector)
+		}
+		if nodes = sel.MatchAll(doc); len(nodes) == 0 {
+			return Page{}, errors.Errorf("no elements matched %q", selector)
+		}
+	}
+
+	lines := []string{}
+	for _, n := range nodes {
+		lines = append(lines, textLines(n)...)
+	}
+	res.Text = strings.Join(lines, "\n")
+	return res, nil
+}
+
+// Hash returns hash of page's text
+func (p Page) Hash() string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(p.Text)))
+}
+
+// textLines returns visible text of the node, a line per block element, without empty lines
+func textLines(n *html.Node) []string {
+	var buf strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			buf.WriteString(n.Data)
+			return
+		case n.Type == html.ElementNode && watchSkip[n.DataAtom]:
+			return
+		case n.Type == html.ElementNode && watchBlocks[n.DataAtom]:
+			buf.WriteString("\n")
+			defer buf.WriteString("\n")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+
+	res := []string{}
+	for _, l := range strings.Split(buf.String(), "\n") {
+		if l = strings.Join(strings.Fields(l), " "); l != "" {
+			res = append(res, l)
+		}
+	}
+	return res
+}
+
+// TextDiff returns changed lines of the new text, added ones prefixed by "+ " and removed ones by "- ".
+// Shows up to 100 changed lines.
+func TextDiff(oldText, newText string) string {
+	a, b := splitLines(oldText), splitLines(newText)
+
+	// common head and tail are not diffed
+	head := 0
+	for head < len(a) && head < len(b) && a[head] == b[head] {
+		head++
+	}
+	tail := 0
+	for tail < len(a)-head && tail < len(b)-head && a[len(a)-1-tail] == b[len(b)-1-tail] {
+		tail++
+	}
+	a, b = a[head:len(a)-tail], b[head:len(b)-tail]
+
+	res := []string{}
+	add := func(prefix, line string) {
+		res = append(res, prefix+line)
+	}
+	if len(a)*len(b) > 4*1024*1024 {
+		// too big for lcs, everything is replaced
+		for _, l := range a {
+			add("- ", l)
+		}
+		for _, l := range b {
+			add("+ ", l)
+		}
+	} else {
+		// lcs[i][j] is length of the longest common subsequence of a[i:] and b[j:]
+		lcs := make([][]int, len(a)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(b)+1)
+		}
+		for i := len(a) - 1; i >= 0; i-- {
+			for j := len(b) - 1; j >= 0; j-- {
+				switch {
+				case a[i] == b[j]:
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				case lcs[i+1][j] >= lcs[i][j+1]:
+					lcs[i][j] = lcs[i+1][j]
+				default:
+					lcs[i][j] = lcs[i][j+1]
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < len(a) || j < len(b) {
+			switch {
+			case i < len(a) && j < len(b) && a[i] == b[j]:
+				i++
+				j++
+			case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+				add("- ", a[i])
+				i++
+			default:
+				add("+ ", b[j])
+				j++
+			}
+		}
+	}
+
+	if len(res) > maxDiffLines {
+		res = append(res[:maxDiffLines], fmt.Sprintf("... and %d more", len(res)-maxDiffLines))
+	}
+	return strings.Join(res, "\n")
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, "\n")
+}
@@ -0,0 +1,79 @@
+package feed
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatch(t *testing.T) {
+	page := `<html><head><title>Announcements</title><style>p {color: red}</style></head>
+		<body><nav><a href="/">Home</a> | <a href="/news">News</a></nav>
+		<div id="news">
+			<h2>Schedule</h2>
+			<ul><li>Monday:   <b>live</b> show</li><li>Friday: rerun</li></ul>
+			<p>Line 1<br>Line 2</p>
+			<script>var updated = "12:00";</script>
+		</div>
+		<footer>Generated at 12:00</footer></body></html>`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(page))
+	}))
+	defer ts.Close()
+
+	res, err := Watch(context.Background(), ts.URL, "#news", ParseOpts{})
+	require.NoError(t, err)
+	assert.Equal(t, "Announcements", res.Title)
+	assert.Equal(t, "Schedule\nMonday: live show\nFriday: rerun\nLine 1\nLine 2", res.Text)
+	assert.Len(t, res.Hash(), 64)
+
+	res, err = Watch(context.Background(), ts.URL, "", ParseOpts{})
+	require.NoError(t, err)
+	assert.Equal(t, "Home | News\nSchedule\nMonday: live show\nFriday: rerun\nLine 1\nLine 2\nGenerated at 12:00", res.Text)
+
+	_, err = Watch(context.Background(), ts.URL, "#missing", ParseOpts{})
+	assert.EqualError(t, err, `no elements matched "#missing"`)
+
+	v := &Validators{}
+	_, err = Watch(context.Background(), ts.URL, "#news", ParseOpts{Validators: v})
+	require.NoError(t, err)
+	_, err = Watch(context.Background(), ts.URL, "#news", ParseOpts{Validators: v})
+	assert.Equal(t, ErrNotModified, err, "the same content")
+}
+
+func TestValidateSelector(t *testing.T) {
+	assert.NoError(t, ValidateSelector(""))
+	assert.NoError(t, ValidateSelector("#news .item"))
+	assert.EqualError(t, ValidateSelector("div["), `invalid selector "div[": expected identifier, found EOF instead`)
+}
+
+func TestTextDiff(t *testing.T) {
+	tbl := []struct {
+		old, new, res string
+	}{
+		{"a\nb\nc", "a\nb\nc", ""},
+		{"", "a\nb", "+ a\n+ b"},
+		{"a\nb", "", "- a\n- b"},
+		{"a\nb\nc", "a\nx\nc", "- b\n+ x"},
+		{"a\nb\nc\nd", "b\nc\nd\ne", "- a\n+ e"},
+		{"head\n1\n2\n3\ntail", "head\n0\n1\n3\n4\ntail", "+ 0\n- 2\n+ 4"},
+	}
+	for _, tt := range tbl {
+		assert.Equal(t, tt.res, TextDiff(tt.old, tt.new), "%q -> %q", tt.old, tt.new)
+	}
+
+	lines := make([]string, 150)
+	for i := range lines {
+		lines[i] = "line"
+	}
+	res := TextDiff("", strings.Join(lines, "\n"))
+	assert.Equal(t, 101, len(strings.Split(res, "\n")))
+	assert.True(t, strings.HasSuffix(res, "\n... and 50 more"))
+}
@@ -0,0 +1,185 @@
+// Package inbox provides smtp receiver of newsletters and parsing of received messages
+package inbox
+
+import (
+	"bytes"
+	"encoding/base64"
+	"html"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/html/charset"
+)
+
+// Message is parsed mail message
+type Message struct {
+	MessageID   string
+	From        string // sender's name, address if no name
+	Subject     string
+	Date        time.Time // zero if not set or invalid
+	HTML        string    // html body, text body converted to html if message has no html part
+	Attachments []Attachment
+}
+
+// Attachment is a file attached to message, inline parts referenced from html by cid: urls included
+type Attachment struct {
+	Name        string
+	ContentType string
+	ContentID   string // without angle brackets
+	Data        []byte
+}
+
+var wordDecoder = &mime.WordDecoder{CharsetReader: charset.NewReaderLabel}
+
+// ParseMessage parses RFC 5322 message with MIME parts
+func ParseMessage(r io.Reader) (Message, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return Message{}, errors.Wrap(err, "can't read message")
+	}
+
+	res := Message{
+		MessageID: strings.Trim(strings.TrimSpace(msg.Header.Get("Message-Id")), "<>"),
+		Subject:   decodeHeader(msg.Header.Get("Subject")),
+	}
+	if dt, e := msg.Header.Date(); e == nil {
+		res.Date = dt
+	}
+	if from, e := (&mail.AddressParser{WordDecoder: wordDecoder}).Parse(msg.Header.Get("From")); e == nil {
+		res.From = from.Name
+		if res.From == "" {
+			res.From = from.Address
+		}
+	}
+
+	var text string
+	if err = res.walk(textproto.MIMEHeader(msg.Header), msg.Body, &text); err != nil {
+		return Message{}, err
+	}
+	if res.HTML == "" && text != "" {
+		res.HTML = textToHTML(text)
+	}
+	return res, nil
+}
+
+// walk collects html and text bodies and attachments of the part, recursively for multipart ones
+func (m *Message) walk(header textproto.MIMEHeader, body io.Reader, text *string) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, e := mr.NextPart()
+			if e == io.EOF {
+				return nil
+			}
+			if e != nil {
+				return errors.Wrap(e, "can't read multipart")
+			}
+			if e = m.walk(part.Header, part, text); e != nil {
+				return e
+			}
+		}
+	}
+
+	data, err := ioutil.ReadAll(decodeTransfer(header.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return errors.Wrapf(err, "can't read %s part", mediaType)
+	}
+
+	disposition, dparams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	name := decodeHeader(dparams["filename"])
+	if name == "" {
+		name = decodeHeader(params["name"])
+	}
+	isBody := disposition != "attachment" && name == "" && (mediaType == "text/html" || mediaType == "text/plain")
+
+	switch {
+	case isBody && mediaType == "text/html" && m.HTML == "":
+		m.HTML = toUTF8(data, params["charset"])
+	case isBody && mediaType == "text/plain" && *text == "":
+		*text = toUTF8(data, params["charset"])
+	case !isBody:
+		m.Attachments = append(m.Attachments, Attachment{
+			Name:        attachmentName(name, mediaType, len(m.Attachments)),
+			ContentType: mediaType,
+			ContentID:   strings.Trim(strings.TrimSpace(header.Get("Content-Id")), "<>"),
+			Data:        data,
+		})
+	}
+	return nil
+}
+
+// decodeTransfer decodes base64 and quoted-printable parts, multipart reader decodes quoted-printable ones itself
+func decodeTransfer(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
+	return r
+}
+
+// toUTF8 converts text in given charset to utf-8, returns as is for unknown charsets
+func toUTF8(data []byte, label string) string {
+	if label == "" {
+		return string(data)
+	}
+	r, err := charset.NewReaderLabel(label, bytes.NewReader(data))
+	if err != nil {
+		return string(data)
+	}
+	res, err := ioutil.ReadAll(r)
+	if err != nil {
+		return string(data)
+	}
+	return string(res)
+}
+
+// decodeHeader decodes RFC 2047 encoded words, returns header as is if can't
+func decodeHeader(s string) string {
+	res, err := wordDecoder.DecodeHeader(s)
+	if err != nil {
+		return strings.TrimSpace(s)
+	}
+	return strings.TrimSpace(res)
+}
+
+// textToHTML escapes plain text and keeps its paragraphs and line breaks
+func textToHTML(text string) string {
+	text = strings.ReplaceAll(strings.TrimSpace(text), "\r\n", "\n")
+	paragraphs := []string{}
+	for _, p := range strings.Split(text, "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			paragraphs = append(paragraphs, "<p>"+strings.ReplaceAll(html.EscapeString(p), "\n", "<br>")+"</p>")
+		}
+	}
+	return strings.Join(paragraphs, "\n")
+}
+
+// attachmentName returns base name of attachment, makes one from the media type if not set
+func attachmentName(name, mediaType string, n int) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name != "." && name != "/" && name != "" {
+		return name
+	}
+	name = "attachment-" + strconv.Itoa(n+1)
+	if exts, err := mime.ExtensionsByType(mediaType); err == nil && len(exts) > 0 {
+		name += exts[0]
+	}
+	return name
+}
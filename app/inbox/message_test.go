@@ -0,0 +1,80 @@
+package inbox
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMessage(t *testing.T) {
+	raw := "From: =?utf-8?b?0J/RgNC40LLQtdGC?= <news@example.com>\r\n" +
+		"To: weekly-42@fm.example.com\r\n" +
+		"Subject: =?utf-8?q?Weekly_=E2=84=9612?=\r\n" +
+		"Date: Sat, 10 Jul 2021 18:30:00 +0300\r\n" +
+		"Message-ID: <abc.123@example.com>\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/mixed; boundary=\"mixed\"\r\n" +
+		"\r\n" +
+		"--mixed\r\n" +
+		"Content-Type: multipart/alternative; boundary=\"alt\"\r\n" +
+		"\r\n" +
+		"--alt\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		"plain text\r\n" +
+		"--alt\r\n" +
+		"Content-Type: multipart/related; boundary=\"rel\"\r\n" +
+		"\r\n" +
+		"--rel\r\n" +
+		"Content-Type: text/html; charset=windows-1251\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n" +
+		"\r\n" +
+		"<p>=CF=F0=E8=E2=E5=F2 <img src=3D\"cid:logo@x\"></p>\r\n" +
+		"--rel\r\n" +
+		"Content-Type: image/png\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"Content-ID: <logo@x>\r\n" +
+		"\r\n" +
+		"iVBORw0K\r\n" +
+		"GgoA\r\n" +
+		"--rel--\r\n" +
+		"--alt--\r\n" +
+		"--mixed\r\n" +
+		"Content-Type: audio/mpeg\r\n" +
+		"Content-Disposition: attachment; filename*=utf-8''%D0%B2%D1%8B%D0%BF%D1%83%D1%81%D0%BA.mp3\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"\r\n" +
+		"SUQz\r\n" +
+		"--mixed--\r\n"
+
+	msg, err := ParseMessage(strings.NewReader(raw))
+	require.NoError(t, err)
+	assert.Equal(t, "abc.123@example.com", msg.MessageID)
+	assert.Equal(t, "Привет", msg.From)
+	assert.Equal(t, "Weekly №12", msg.Subject)
+	assert.Equal(t, time.Date(2021, 7, 10, 15, 30, 0, 0, time.UTC), msg.Date.UTC())
+	assert.Equal(t, `<p>Привет <img src="cid:logo@x"></p>`, strings.TrimSpace(msg.HTML))
+
+	require.Len(t, msg.Attachments, 2)
+	assert.Equal(t, Attachment{Name: msg.Attachments[0].Name, ContentType: "image/png", ContentID: "logo@x",
+		Data: []byte("\x89PNG\r\n\x1a\n\x00")}, msg.Attachments[0])
+	assert.True(t, strings.HasPrefix(msg.Attachments[0].Name, "attachment-1"))
+	assert.Equal(t, Attachment{Name: "выпуск.mp3", ContentType: "audio/mpeg", Data: []byte("ID3")}, msg.Attachments[1])
+}
+
+func TestParseMessagePlain(t *testing.T) {
+	raw := "From: news@example.com\r\nSubject: plain\r\n\r\nline 1\r\nline <2>\r\n\r\n\r\nparagraph 2\r\n"
+	msg, err := ParseMessage(strings.NewReader(raw))
+	require.NoError(t, err)
+	assert.Equal(t, "news@example.com", msg.From)
+	assert.Equal(t, "plain", msg.Subject)
+	assert.True(t, msg.Date.IsZero())
+	assert.Equal(t, "<p>line 1<br>line &lt;2&gt;</p>\n<p>paragraph 2</p>", msg.HTML)
+	assert.Empty(t, msg.Attachments)
+
+	_, err = ParseMessage(strings.NewReader("not a message"))
+	assert.Error(t, err)
+}
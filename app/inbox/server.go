@@ -0,0 +1,247 @@
+package inbox
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+)
+
+// Server is minimal smtp server receiving mail for accepted recipients. No auth and no tls, as it's meant to get
+// newsletters sent to unguessable addresses, the same way as MX of any domain does.
+type Server struct {
+	Addr    string                                  // listen address, like ":2525"
+	Domain  string                                  // announced in greeting, localhost if not set
+	MaxSize int64                                   // max message size, 10M if not set
+	Timeout time.Duration                           // idle timeout of session, 5m if not set
+	Accept  func(rcpt string) bool                  // checks recipient's address
+	Deliver func(rcpts []string, msg Message) error // called for each parsed message
+
+	mu       sync.Mutex
+	listener net.Listener
+}
+
+const maxSessions = 100
+
+// Run listens on s.Addr and serves smtp sessions, blocks till Shutdown
+func (s *Server) Run() error {
+	l, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	log.Printf("[INFO] smtp receiver on %s", s.Addr)
+	return s.Serve(l)
+}
+
+// Serve accepts smtp sessions on the listener, blocks till Shutdown
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	s.listener = l
+	s.mu.Unlock()
+
+	sem := make(chan struct{}, maxSessions)
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() { // nolint
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem }()
+			s.session(conn)
+		}()
+	}
+}
+
+// Shutdown stops accepting new sessions
+func (s *Server) Shutdown() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+// envelope of the message in progress
+type envelope struct {
+	from  string
+	rcpts []string
+}
+
+func (s *Server) session(conn net.Conn) {
+	defer conn.Close() // nolint
+	tp := textproto.NewConn(conn)
+	remote := conn.RemoteAddr().String()
+
+	// reply sends single or multiline reply, returns false if failed
+	reply := func(code int, lines ...string) bool {
+		for i, l := range lines {
+			sep := "-"
+			if i == len(lines)-1 {
+				sep = " "
+			}
+			if err := tp.PrintfLine("%d%s%s", code, sep, l); err != nil {
+				log.Printf("[DEBUG] smtp reply to %s failed, %v", remote, err)
+				return false
+			}
+		}
+		return true
+	}
+
+	if !reply(220, s.domain()+" ESMTP feed-master") {
+		return
+	}
+
+	var env *envelope
+	for {
+		_ = conn.SetDeadline(time.Now().Add(s.timeout()))
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd, arg := line, ""
+		if i := strings.IndexByte(line, ' '); i > 0 {
+			cmd, arg = line[:i], strings.TrimSpace(line[i+1:])
+		}
+
+		ok := true
+		switch strings.ToUpper(cmd) {
+		case "HELO":
+			env = nil
+			ok = reply(250, s.domain())
+		case "EHLO":
+			env = nil
+			ok = reply(250, s.domain(), fmt.Sprintf("SIZE %d", s.maxSize()), "8BITMIME")
+		case "MAIL":
+			from, e := parsePath(arg, "FROM:")
+			if e != nil {
+				ok = reply(501, e.Error())
+				break
+			}
+			env = &envelope{from: from}
+			ok = reply(250, "ok")
+		case "RCPT":
+			if env == nil {
+				ok = reply(503, "need MAIL first")
+				break
+			}
+			rcpt, e := parsePath(arg, "TO:")
+			if e != nil || rcpt == "" {
+				ok = reply(501, "invalid recipient")
+				break
+			}
+			if s.Accept == nil || !s.Accept(rcpt) {
+				ok = reply(550, "no such mailbox "+rcpt)
+				break
+			}
+			env.rcpts = append(env.rcpts, rcpt)
+			ok = reply(250, "ok")
+		case "DATA":
+			if env == nil || len(env.rcpts) == 0 {
+				ok = reply(503, "need RCPT first")
+				break
+			}
+			if ok = reply(354, "end data with <CR><LF>.<CR><LF>"); ok {
+				code, msg := s.data(tp, env)
+				log.Printf("[INFO] smtp message from %s (%s) to %v, %d %s", env.from, remote, env.rcpts, code, msg)
+				ok = reply(code, msg)
+			}
+			env = nil
+		case "RSET":
+			env = nil
+			ok = reply(250, "ok")
+		case "NOOP":
+			ok = reply(250, "ok")
+		case "VRFY":
+			ok = reply(252, "cannot verify user, but will accept message")
+		case "QUIT":
+			reply(221, "bye")
+			return
+		default:
+			ok = reply(502, "command not implemented")
+		}
+		if !ok {
+			return
+		}
+	}
+}
+
+// data reads message of DATA command and delivers it, returns reply code and message
+func (s *Server) data(tp *textproto.Conn, env *envelope) (int, string) {
+	dr := tp.DotReader()
+	body, err := ioutil.ReadAll(io.LimitReader(dr, s.maxSize()+1))
+	if err != nil {
+		return 451, "failed to read message"
+	}
+	if int64(len(body)) > s.maxSize() {
+		if _, err = io.Copy(ioutil.Discard, dr); err != nil {
+			return 451, "failed to read message"
+		}
+		return 552, "message too big"
+	}
+
+	msg, err := ParseMessage(bytes.NewReader(body))
+	if err != nil {
+		log.Printf("[WARN] can't parse message from %s, %v", env.from, err)
+		return 554, "can't parse message"
+	}
+	if s.Deliver != nil {
+		if err := s.Deliver(env.rcpts, msg); err != nil {
+			log.Printf("[WARN] can't deliver message from %s, %v", env.from, err)
+			return 451, "failed to deliver message"
+		}
+	}
+	return 250, "ok"
+}
+
+// parsePath returns address of MAIL or RCPT argument like "FROM:<user@example.com> SIZE=123", empty for null path
+func parsePath(arg, prefix string) (string, error) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", fmt.Errorf("syntax error, %s expected", prefix)
+	}
+	path := strings.TrimSpace(arg[len(prefix):])
+	if strings.HasPrefix(path, "<") {
+		end := strings.IndexByte(path, '>')
+		if end < 0 {
+			return "", fmt.Errorf("syntax error, unclosed path")
+		}
+		return strings.TrimSpace(path[1:end]), nil
+	}
+	if i := strings.IndexByte(path, ' '); i > 0 {
+		path = path[:i]
+	}
+	return path, nil
+}
+
+func (s *Server) domain() string {
+	if s.Domain == "" {
+		return "localhost"
+	}
+	return s.Domain
+}
+
+func (s *Server) maxSize() int64 {
+	if s.MaxSize <= 0 {
+		return 10 * 1024 * 1024
+	}
+	return s.MaxSize
+}
+
+func (s *Server) timeout() time.Duration {
+	if s.Timeout <= 0 {
+		return 5 * time.Minute
+	}
+	return s.Timeout
+}
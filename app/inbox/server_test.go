@@ -0,0 +1,108 @@
+package inbox
+
+import (
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	var mu sync.Mutex
+	var delivered []Message
+	var rcpts [][]string
+	srv := &Server{
+		Domain:  "fm.example.com",
+		MaxSize: 1024,
+		Accept:  func(rcpt string) bool { return strings.HasPrefix(rcpt, "news@") },
+		Deliver: func(to []string, msg Message) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if msg.Subject == "fail" {
+				return errors.New("failed")
+			}
+			delivered = append(delivered, msg)
+			rcpts = append(rcpts, to)
+			return nil
+		},
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = srv.Serve(l) }()
+	defer srv.Shutdown() // nolint
+	addr := l.Addr().String()
+
+	err = smtp.SendMail(addr, nil, "sender@example.com", []string{"news@fm.example.com"},
+		[]byte("From: sender@example.com\r\nSubject: hello\r\n\r\n.leading dot\r\n"))
+	require.NoError(t, err)
+
+	err = smtp.SendMail(addr, nil, "sender@example.com", []string{"other@fm.example.com"}, []byte("Subject: x\r\n\r\nx"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "550")
+
+	err = smtp.SendMail(addr, nil, "sender@example.com", []string{"news@fm.example.com"},
+		[]byte("Subject: big\r\n\r\n"+strings.Repeat("x", 2048)))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "552")
+
+	err = smtp.SendMail(addr, nil, "sender@example.com", []string{"news@fm.example.com"}, []byte("Subject: fail\r\n\r\nx"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "451")
+
+	mu.Lock()
+	require.Len(t, delivered, 1)
+	assert.Equal(t, "hello", delivered[0].Subject)
+	assert.Equal(t, "<p>.leading dot</p>", delivered[0].HTML)
+	assert.Equal(t, [][]string{{"news@fm.example.com"}}, rcpts)
+	mu.Unlock()
+
+	// commands out of order
+	conn, err := textproto.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, _, err = conn.ReadResponse(220)
+	require.NoError(t, err)
+	for _, tt := range []struct {
+		cmd  string
+		code int
+	}{
+		{"HELO client", 250}, {"RCPT TO:<news@x>", 503}, {"MAIL FROM <a@b>", 501}, {"MAIL FROM:<>", 250},
+		{"DATA", 503}, {"NOOP", 250}, {"STARTTLS", 502}, {"QUIT", 221},
+	} {
+		id, err := conn.Cmd(tt.cmd)
+		require.NoError(t, err)
+		conn.StartResponse(id)
+		code, _, _ := conn.ReadResponse(0)
+		conn.EndResponse(id)
+		assert.Equal(t, tt.code, code, tt.cmd)
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	tbl := []struct {
+		arg, prefix, res string
+		err              bool
+	}{
+		{"FROM:<a@example.com>", "FROM:", "a@example.com", false},
+		{"from: <a@example.com> SIZE=123 BODY=8BITMIME", "FROM:", "a@example.com", false},
+		{"FROM:<>", "FROM:", "", false},
+		{"TO:b@example.com NOTIFY=NEVER", "TO:", "b@example.com", false},
+		{"TO:<b@example.com", "TO:", "", true},
+		{"FROM:<a@example.com>", "TO:", "", true},
+	}
+	for _, tt := range tbl {
+		res, err := parsePath(tt.arg, tt.prefix)
+		if tt.err {
+			assert.Error(t, err, tt.arg)
+			continue
+		}
+		require.NoError(t, err, tt.arg)
+		assert.Equal(t, tt.res, res, tt.arg)
+	}
+}
@@ -2,13 +2,18 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"time"
 
 	log "github.com/go-pkgz/lgr"
 	"github.com/jessevdk/go-flags"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
 
 	"github.com/umputun/feed-master/app/api"
+	"github.com/umputun/feed-master/app/feed"
+	"github.com/umputun/feed-master/app/inbox"
 	"github.com/umputun/feed-master/app/proc"
 	"github.com/umputun/feed-master/app/store"
 )
@@ -17,11 +22,27 @@ type options struct {
 	DB   string `short:"c" long:"db" env:"FM_DB" default:"var/feed-master.bdb" description:"bolt db file"`
 	Conf string `short:"f" long:"conf" env:"FM_CONF" default:"feed-master.yml" description:"config file (yml)"`
 
-	UpdateInterval time.Duration `long:"update-interval" env:"UPDATE_INTERVAL" default:"1m" description:"update interval, overrides config"`
+	// single feed overrides
+	Feed            string        `long:"feed" env:"FM_FEED" description:"single feed, overrides config"`
+	UpdateInterval  time.Duration `long:"update-interval" env:"UPDATE_INTERVAL" description:"update interval, overrides config"`
+	TelegramChannel string        `long:"telegram_chan" env:"TELEGRAM_CHAN" description:"single telegram channel, overrides config"`
+
+	FetchTimeout time.Duration `long:"fetch-timeout" env:"FETCH_TIMEOUT" default:"30s" description:"feed fetch timeout"`
+	FetchRetries int           `long:"fetch-retries" env:"FETCH_RETRIES" default:"2" description:"feed fetch retries"`
+	MaxBodySize  int64         `long:"max-body-size" env:"MAX_BODY_SIZE" default:"10485760" description:"max size of fetched feed"`
+	UserAgent    string        `long:"user-agent" env:"USER_AGENT" description:"user agent for fetching"`
+	Proxy        string        `long:"proxy" env:"FM_PROXY" description:"proxy url for fetching, HTTP(S)_PROXY used if not set"`
 
 	TelegramServer  string        `long:"telegram_server" env:"TELEGRAM_SERVER" default:"https://api.telegram.org" description:"telegram bot api server"`
 	TelegramToken   string        `long:"telegram_token" env:"TELEGRAM_TOKEN" description:"telegram token"`
 	TelegramTimeout time.Duration `long:"telegram_timeout" env:"TELEGRAM_TIMEOUT" default:"1m" description:"telegram timeout"`
+	TelegramAdmins  []string      `long:"telegram_admins" env:"TELEGRAM_ADMINS" env-delim:"," description:"publishers, ids or usernames"`
+
+	AdminPasswd string `long:"admin-passwd" env:"ADMIN_PASSWD" description:"password of admin api, disabled if not set"`
+
+	MediaDir   string `long:"media" env:"FM_MEDIA" default:"var/media" description:"directory of stored files"`
+	SMTPAddr   string `long:"smtp-addr" env:"SMTP_ADDR" description:"listen address of smtp receiver, disabled if not set"`
+	SMTPDomain string `long:"smtp-domain" env:"SMTP_DOMAIN" default:"localhost" description:"domain of smtp receiver"`
 
 	Dbg bool `long:"dbg" env:"DEBUG" description:"debug mode"`
 }
@@ -36,33 +57,120 @@ func main() {
 	}
 	setupLog(opts.Dbg)
 
-	var err error
-	var conf = &proc.Conf{}
-
-	conf.System.UpdateInterval = opts.UpdateInterval
+	conf, err := makeConfig(opts)
+	if err != nil {
+		log.Fatalf("[ERROR] can't make config, %v", err)
+	}
 
 	db, err := store.NewBoldStore(opts.DB)
 	if err != nil {
 		log.Fatalf("[ERROR] can't open db %s, %v", opts.DB, err)
 	}
+	itemsStore := &proc.BoltDB{DB: db.DB}
 
-	telegramBot, err := proc.NewTelegramV2Client(opts.TelegramToken, opts.TelegramServer, opts.TelegramTimeout)
+	fetcher, err := feed.NewFetcher(feed.FetchOpts{Timeout: opts.FetchTimeout, Retries: opts.FetchRetries,
+		MaxBodySize: opts.MaxBodySize, UserAgent: opts.UserAgent, Proxy: opts.Proxy})
 	if err != nil {
-		log.Fatalf("[ERROR] failed to initialize telegram client %s, %v", opts.TelegramToken, err)
+		log.Fatalf("[ERROR] can't make fetcher, %v", err)
 	}
 
-	telegramBot.Start()
+	var telegramNotif proc.TelegramNotif
+	var telegramBot *proc.TelegramClientV2
+	if opts.TelegramToken != "" {
+		telegramBot, err = proc.NewTelegramV2Client(opts.TelegramToken, opts.TelegramServer, opts.TelegramTimeout)
+		if err != nil {
+			log.Fatalf("[ERROR] failed to initialize telegram client %s, %v", opts.TelegramToken, err)
+		}
+		telegramBot.Fetcher = fetcher
+		telegramNotif = telegramBot
+	}
 
-	p := &proc.Processor{Conf: conf, TelegramBot: telegramBot}
-	go p.Do(db)
+	p := &proc.Processor{Conf: conf, Store: itemsStore, TelegramNotif: telegramNotif, Fetcher: fetcher, FeedsStore: db,
+		MediaDir: opts.MediaDir}
+	if telegramBot != nil {
+		telegramBot.AcceptPost, telegramBot.DeliverPost = p.AcceptPost, p.DeliverPost
+		telegramBot.Admins, telegramBot.Publish = opts.TelegramAdmins, p.Publish
+		telegramBot.Start()
+	}
+	go p.Do()
+
+	if opts.SMTPAddr != "" {
+		smtpServer := &inbox.Server{Addr: opts.SMTPAddr, Domain: opts.SMTPDomain, Accept: p.AcceptMail, Deliver: p.DeliverMail}
+		go func() {
+			if err := smtpServer.Run(); err != nil {
+				log.Printf("[WARN] smtp receiver terminated, %v", err)
+			}
+		}()
+	}
 
 	server := api.Server{
-		Version: revision,
-		Conf:    *conf,
+		Version:     revision,
+		Conf:        *conf,
+		Store:       itemsStore,
+		MediaDir:    opts.MediaDir,
+		AdminPasswd: opts.AdminPasswd,
+		Fetcher:     fetcher,
 	}
 	server.Run(8080)
 }
 
+// makeConfig loads config file or makes single feed config, applies cli overrides and validates the result
+func makeConfig(opts options) (*proc.Conf, error) {
+	var conf *proc.Conf
+	if opts.Feed != "" { // single feed (no config) mode
+		conf = singleFeedConf(opts.Feed, opts.UpdateInterval)
+	}
+
+	if opts.Feed == "" {
+		var err error
+		if conf, err = loadConfig(opts.Conf); err != nil {
+			return nil, errors.Wrapf(err, "can't load config %s", opts.Conf)
+		}
+	}
+
+	if opts.UpdateInterval > 0 {
+		conf.System.UpdateInterval = opts.UpdateInterval
+	}
+
+	if opts.TelegramChannel != "" {
+		for name, f := range conf.Feeds {
+			f.TelegramChannel = opts.TelegramChannel
+			f.TelegramChannels = nil
+			conf.Feeds[name] = f
+		}
+	}
+
+	conf.SetDefaults()
+	if err := conf.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid config")
+	}
+	return conf, nil
+}
+
+func singleFeedConf(feedURL string, updateInterval time.Duration) *proc.Conf {
+	conf := proc.Conf{}
+	f := proc.Feed{
+		Sources: []proc.Source{{Name: "auto", URL: feedURL}},
+	}
+	conf.Feeds = map[string]proc.Feed{"auto": f}
+	conf.System.UpdateInterval = updateInterval
+	return &conf
+}
+
+func loadConfig(fname string) (res *proc.Conf, err error) {
+	res = &proc.Conf{}
+	data, err := ioutil.ReadFile(fname) // nolint
+	if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
 func setupLog(dbg bool) {
 	if dbg {
 		log.Setup(log.Debug, log.CallerFile, log.Msec, log.LevelBraces)
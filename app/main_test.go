@@ -1,12 +1,21 @@
 package main
 
 import (
+	"fmt"
 	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
 	"strconv"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/feed-master/app/api"
+	"github.com/umputun/feed-master/app/proc"
 )
 
 func TestLoadConfig(t *testing.T) {
@@ -92,3 +101,97 @@ func TestSingleFeedConf(t *testing.T) {
 		})
 	}
 }
+
+func TestMakeConfig(t *testing.T) {
+	data := []byte(`
+feeds:
+  first:
+    title: "blah 1"
+    telegram_channel: "chan1"
+    sources:
+      - name: nnn1
+        url: http://aa.com/u1
+  second:
+    title: "blah 2"
+    sources:
+      - name: mmm1
+        url: https://bbb.com/u1
+system:
+  update: 10m
+`)
+	assert.Nil(t, ioutil.WriteFile("/tmp/fm-make.yml", data, 0777), "failed write yml") // nolint
+
+	t.Run("config file", func(t *testing.T) {
+		conf, err := makeConfig(options{Conf: "/tmp/fm-make.yml"})
+		require.NoError(t, err)
+		assert.Equal(t, 2, len(conf.Feeds))
+		assert.Equal(t, 10*time.Minute, conf.System.UpdateInterval)
+		assert.Equal(t, "chan1", conf.Feeds["first"].TelegramChannel)
+		assert.Equal(t, "", conf.Feeds["second"].TelegramChannel)
+		assert.Equal(t, 100, conf.System.MaxTotal, "defaults applied")
+	})
+
+	t.Run("config file with overrides", func(t *testing.T) {
+		conf, err := makeConfig(options{Conf: "/tmp/fm-make.yml", UpdateInterval: time.Minute, TelegramChannel: "over"})
+		require.NoError(t, err)
+		assert.Equal(t, time.Minute, conf.System.UpdateInterval)
+		assert.Equal(t, "over", conf.Feeds["first"].TelegramChannel)
+		assert.Equal(t, "over", conf.Feeds["second"].TelegramChannel)
+	})
+
+	t.Run("single feed", func(t *testing.T) {
+		conf, err := makeConfig(options{Conf: "/tmp/not-exists.yml", Feed: "http://example.com/rss", TelegramChannel: "ch"})
+		require.NoError(t, err)
+		require.Equal(t, 1, len(conf.Feeds))
+		assert.Equal(t, "http://example.com/rss", conf.Feeds["auto"].Sources[0].URL)
+		assert.Equal(t, "ch", conf.Feeds["auto"].TelegramChannel)
+		assert.Equal(t, 5*time.Minute, conf.System.UpdateInterval, "default interval")
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := makeConfig(options{Conf: "/tmp/not-exists.yml"})
+		assert.EqualError(t, err, "can't load config /tmp/not-exists.yml: open /tmp/not-exists.yml: no such file or directory")
+	})
+}
+
+func TestServerSlowMediaDownload(t *testing.T) {
+	mediaDir, err := ioutil.TempDir("", "fm-media")
+	require.NoError(t, err)
+	defer os.RemoveAll(mediaDir) // nolint
+	require.NoError(t, os.Mkdir(filepath.Join(mediaDir, "first"), 0o700))
+	data := make([]byte, 32*1024*1024) // larger than socket buffers, server blocks on write till client reads
+	require.NoError(t, ioutil.WriteFile(filepath.Join(mediaDir, "first", "file.mp3"), data, 0o600))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	require.NoError(t, l.Close())
+
+	srv := api.Server{
+		Conf:              proc.Conf{Feeds: map[string]proc.Feed{"first": {}}},
+		MediaDir:          mediaDir,
+		WriteTimeout:      200 * time.Millisecond,
+		MediaWriteTimeout: time.Minute,
+	}
+	go srv.Run(port)
+
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", port)
+	require.Eventually(t, func() bool {
+		resp, e := http.Get(baseURL + "/ping")
+		if e != nil {
+			return false
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+
+	resp, err := http.Get(baseURL + "/files/first/file.mp3")
+	require.NoError(t, err)
+	defer resp.Body.Close() // nolint
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	time.Sleep(time.Second) // slow client, response outlives server's write timeout
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, len(data), len(body))
+}
@@ -1,11 +1,27 @@
 // Package models contains DAO objects
 package models
 
+import "time"
+
 // Feed presents
 type Feed struct {
 	// Key []byte
-	Title string `json:"title"`
-	URL   string `json:"url"`
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	FeedSet string `json:"feed_set,omitempty"` // feed-set fetching the feed, feeds shared by feed-sets kept for each one
+
+	// validators of the last fetched content, for conditional GET
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	ContentHash  string `json:"content_hash,omitempty"`
+}
+
+// Snapshot presents the last seen text of watched page
+type Snapshot struct {
+	Key     string    `json:"key"` // feed-set name, page url and selector
+	Hash    string    `json:"hash"`
+	Text    string    `json:"text"`
+	Updated time.Time `json:"updated"`
 }
 
 // User presents
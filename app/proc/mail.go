@@ -0,0 +1,156 @@
+package proc
+
+import (
+	"bytes"
+	"crypto/sha1" // nolint
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/pkg/errors"
+
+	"github.com/umputun/feed-master/app/feed"
+	"github.com/umputun/feed-master/app/inbox"
+)
+
+// mailSource is the pseudo source of items made of received mail, for tag rules
+var mailSource = Source{Name: "email"}
+
+// AcceptMail checks if any feed-set receives mail sent to the address
+func (p *Processor) AcceptMail(rcpt string) bool {
+	return len(p.Conf.mailFeeds(rcpt)) > 0
+}
+
+// DeliverMail saves message as item to feed-sets of its recipients and sends it to telegram.
+// Attachments are stored in MediaDir and linked from the description, the first audio one used as enclosure.
+func (p *Processor) DeliverMail(rcpts []string, msg inbox.Message) error {
+	names := map[string]bool{}
+	for _, rcpt := range rcpts {
+		for _, name := range p.Conf.mailFeeds(rcpt) {
+			names[name] = true
+		}
+	}
+	if len(names) == 0 {
+		return errors.Errorf("no feeds for %v", rcpts)
+	}
+
+	for name := range names {
+		item, err := p.mailItem(name, msg)
+		if err != nil {
+			return errors.Wrapf(err, "can't make item for %s", name)
+		}
+		log.Printf("[INFO] mail %q from %s to %s", item.Title, msg.From, name)
+		if err = p.saveItem(name, p.Conf.Feeds[name], mailSource, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mailFeeds returns names of feed-sets receiving mail sent to the address. Feed-set's email matched
+// with the whole address if it has domain, with the local part otherwise.
+func (c *Conf) mailFeeds(rcpt string) []string {
+	rcpt = strings.ToLower(strings.TrimSpace(rcpt))
+	local := rcpt
+	if i := strings.LastIndex(rcpt, "@"); i >= 0 {
+		local = rcpt[:i]
+	}
+
+	res := []string{}
+	for name, f := range c.Feeds {
+		email := strings.ToLower(strings.TrimSpace(f.Email))
+		if email == "" {
+			continue
+		}
+		if email == rcpt || (!strings.Contains(email, "@") && email == local) {
+			res = append(res, name)
+		}
+	}
+	return res
+}
+
+// mailItem makes item of the message for feed-set, stores attachments
+func (p *Processor) mailItem(name string, msg inbox.Message) (feed.Item, error) {
+	dt := msg.Date
+	if dt.IsZero() {
+		dt = time.Now()
+	}
+	guid := msg.MessageID
+	if guid == "" {
+		guid = fmt.Sprintf("mail-%x", sha1.Sum([]byte(msg.From+msg.Subject+dt.String()+msg.HTML))) // nolint
+	}
+	item := feed.Item{
+		Title:   strings.TrimSpace(msg.Subject),
+		Author:  msg.From,
+		GUID:    guid,
+		PubDate: dt.Format(time.RFC1123Z),
+		DT:      dt,
+	}
+	if item.Title == "" {
+		item.Title = "(no subject)"
+	}
+
+	body := msg.HTML
+	links := []string{}
+	prefix := fmt.Sprintf("%x", sha1.Sum([]byte(guid)))[:8] // nolint
+	for _, att := range msg.Attachments {
+		fileURL, err := p.storeFile(name, prefix+"-"+att.Name, att.Data)
+		if err != nil {
+			return feed.Item{}, errors.Wrapf(err, "can't store attachment %s", att.Name)
+		}
+		if att.ContentID != "" && strings.Contains(body, "cid:"+att.ContentID) {
+			body = strings.ReplaceAll(body, "cid:"+att.ContentID, fileURL)
+			continue
+		}
+		if item.Enclosure.URL == "" && strings.HasPrefix(att.ContentType, "audio/") {
+			item.Enclosure = feed.Enclosure{URL: fileURL, Length: len(att.Data), Type: att.ContentType}
+		}
+		links = append(links, fmt.Sprintf(`<li><a href="%s">%s</a></li>`, fileURL, html.EscapeString(att.Name)))
+	}
+	if len(links) > 0 {
+		body += "\n<p>Attachments:</p>\n<ul>" + strings.Join(links, "") + "</ul>"
+	}
+
+	item.Description = template.HTML(bluemonday.UGCPolicy().Sanitize(body)) // nolint
+	return item, nil
+}
+
+// storeFile saves file of feed-set to MediaDir and returns its url, served by /files route
+func (p *Processor) storeFile(name, fileName string, data []byte) (string, error) {
+	fileURL, _, err := p.storeStream(name, fileName, bytes.NewReader(data))
+	return fileURL, err
+}
+
+// storeStream saves file of feed-set read from r to MediaDir, returns its url and size.
+// Partially written file is removed on error.
+func (p *Processor) storeStream(name, fileName string, r io.Reader) (string, int64, error) {
+	if p.MediaDir == "" {
+		return "", 0, errors.New("no media directory")
+	}
+	dir := filepath.Join(p.MediaDir, name)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return "", 0, err
+	}
+	filePath := filepath.Join(dir, fileName)
+	fh, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o640) // nolint
+	if err != nil {
+		return "", 0, err
+	}
+	size, err := io.Copy(fh, r)
+	if closeErr := fh.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(filePath)
+		return "", 0, err
+	}
+	return p.Conf.System.BaseURL + "/files/" + url.PathEscape(name) + "/" + url.PathEscape(fileName), size, nil
+}
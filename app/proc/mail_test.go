@@ -0,0 +1,124 @@
+package proc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/feed-master/app/inbox"
+)
+
+func TestMailFeeds(t *testing.T) {
+	conf := Conf{Feeds: map[string]Feed{
+		"weekly": {Email: "Weekly-42"},
+		"news":   {Email: "news@fm.example.com"},
+		"other":  {Email: "weekly-42"},
+		"rss":    {},
+	}}
+
+	tbl := []struct {
+		rcpt string
+		res  []string
+	}{
+		{"weekly-42@fm.example.com", []string{"other", "weekly"}},
+		{"WEEKLY-42@another.example.com", []string{"other", "weekly"}},
+		{"news@fm.example.com", []string{"news"}},
+		{"news@another.example.com", []string{}},
+		{"unknown@fm.example.com", []string{}},
+		{"", []string{}},
+	}
+	for _, tt := range tbl {
+		res := conf.mailFeeds(tt.rcpt)
+		sort.Strings(res)
+		assert.Equal(t, tt.res, res, tt.rcpt)
+	}
+
+	p := Processor{Conf: &conf}
+	assert.True(t, p.AcceptMail("news@fm.example.com"))
+	assert.False(t, p.AcceptMail("rss@fm.example.com"))
+}
+
+func TestDeliverMail(t *testing.T) {
+	mediaDir, err := ioutil.TempDir("", "fm-media")
+	require.NoError(t, err)
+	defer os.RemoveAll(mediaDir)
+
+	tmpfile, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmpfile.Name())
+	boltDB, err := NewBoltDB(tmpfile.Name())
+	require.NoError(t, err)
+
+	conf := &Conf{Feeds: map[string]Feed{"weekly": {Email: "weekly", TelegramChannel: "chan",
+		Tags: []TagRule{{Tag: "newsletter", Source: "email"}}}}}
+	conf.System.BaseURL = "https://fm.example.com"
+	notif := &telegramNotifMock{}
+	p := Processor{Conf: conf, Store: boltDB, TelegramNotif: notif, MediaDir: mediaDir}
+
+	msg := inbox.Message{
+		MessageID: "abc@example.com",
+		From:      "Weekly",
+		Subject:   "Issue 12",
+		Date:      time.Date(2021, 7, 10, 15, 30, 0, 0, time.UTC),
+		HTML:      `<p>Hello <img src="cid:logo@x"><script>alert(1)</script></p>`,
+		Attachments: []inbox.Attachment{
+			{Name: "logo.png", ContentType: "image/png", ContentID: "logo@x", Data: []byte("png")},
+			{Name: "issue 12.mp3", ContentType: "audio/mpeg", Data: []byte("ID3")},
+			{Name: "notes.pdf", ContentType: "application/pdf", Data: []byte("pdf")},
+		},
+	}
+	require.NoError(t, p.DeliverMail([]string{"weekly@fm.example.com"}, msg))
+	require.NoError(t, p.DeliverMail([]string{"weekly@fm.example.com"}, msg), "known message saved in place")
+	assert.EqualError(t, p.DeliverMail([]string{"other@fm.example.com"}, msg), "no feeds for [other@fm.example.com]")
+
+	items, err := boltDB.Load("weekly", 10, false)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(items))
+	item := items[0]
+	assert.Equal(t, "Issue 12", item.Title)
+	assert.Equal(t, "Weekly", item.Author)
+	assert.Equal(t, "abc@example.com", item.GUID)
+	assert.Equal(t, []string{"newsletter"}, item.Tags)
+
+	files, err := ioutil.ReadDir(filepath.Join(mediaDir, "weekly"))
+	require.NoError(t, err)
+	require.Equal(t, 3, len(files))
+	prefix := files[0].Name()[:9]
+	fileURL := "https://fm.example.com/files/weekly/" + prefix
+	data, err := ioutil.ReadFile(filepath.Join(mediaDir, "weekly", prefix+"issue 12.mp3"))
+	require.NoError(t, err)
+	assert.Equal(t, "ID3", string(data))
+
+	assert.Equal(t, fileURL+"issue%2012.mp3", item.Enclosure.URL)
+	assert.Equal(t, "audio/mpeg", item.Enclosure.Type)
+	assert.Equal(t, 3, item.Enclosure.Length)
+	assert.Contains(t, string(item.Description), `<img src="`+fileURL+`logo.png">`)
+	assert.Contains(t, string(item.Description), `<a href="`+fileURL+`notes.pdf" rel="nofollow">notes.pdf</a>`)
+	assert.NotContains(t, string(item.Description), "script")
+
+	require.Equal(t, 1, len(notif.sent), "sent once")
+	assert.Equal(t, "chan", notif.sent[0].channel)
+
+	p.MediaDir = ""
+	msg.MessageID = "def@example.com"
+	assert.EqualError(t, p.DeliverMail([]string{"weekly@fm.example.com"}, msg),
+		"can't make item for weekly: can't store attachment logo.png: no media directory")
+
+	msg.Attachments, msg.Subject, msg.Date = nil, "", msg.Date.Add(time.Hour)
+	require.NoError(t, p.DeliverMail([]string{"weekly@fm.example.com"}, msg), "no attachments, no media dir needed")
+	items, err = boltDB.Load("weekly", 10, false)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(items))
+	assert.Equal(t, "(no subject)", items[0].Title)
+
+	require.NoError(t, boltDB.DB.Close())
+	msg.MessageID = "ghi@example.com"
+	err = p.DeliverMail([]string{"weekly@fm.example.com"}, msg)
+	require.Error(t, err, "failed save reported to smtp server")
+	assert.Contains(t, err.Error(), "failed to save ghi@example.com")
+}
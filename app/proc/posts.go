@@ -0,0 +1,121 @@
+package proc
+
+import (
+	"fmt"
+	"html/template"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/pkg/errors"
+	tb "gopkg.in/tucnak/telebot.v2"
+
+	"github.com/umputun/feed-master/app/feed"
+)
+
+// postSource is the pseudo source of items made of telegram channel posts, for tag rules
+var postSource = Source{Name: "telegram"}
+
+// maxPostTitle limits length of titles made of post's text
+const maxPostTitle = 100
+
+// AcceptPost checks if any feed-set ingests posts of the telegram channel
+func (p *Processor) AcceptPost(chat *tb.Chat) bool {
+	return len(p.Conf.postFeeds(chat)) > 0
+}
+
+// DeliverPost saves telegram channel's post as item to feed-sets ingesting the channel.
+// Photo and audio of the post are stored in MediaDir, audio used as enclosure.
+func (p *Processor) DeliverPost(post ChannelPost) error {
+	names := p.Conf.postFeeds(post.Chat)
+	if len(names) == 0 {
+		return errors.Errorf("no feeds for channel %q", post.Chat.Title)
+	}
+
+	for _, name := range names {
+		item, err := p.postItem(name, post)
+		if err != nil {
+			return errors.Wrapf(err, "can't make item for %s", name)
+		}
+		log.Printf("[INFO] telegram post %q of %q to %s", item.Title, post.Chat.Title, name)
+		if err = p.saveItem(name, p.Conf.Feeds[name], postSource, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// postFeeds returns names of feed-sets ingesting posts of the channel, matched by @username or id
+func (c *Conf) postFeeds(chat *tb.Chat) []string {
+	res := []string{}
+	if chat == nil {
+		return res
+	}
+	for name, f := range c.Feeds {
+		src := strings.TrimSpace(f.TelegramSource)
+		if src == "" {
+			continue
+		}
+		if src == strconv.FormatInt(chat.ID, 10) || (chat.Username != "" && strings.EqualFold(strings.TrimPrefix(src, "@"), chat.Username)) {
+			res = append(res, name)
+		}
+	}
+	return res
+}
+
+// postItem makes item of the post for feed-set, stores its files
+func (p *Processor) postItem(name string, post ChannelPost) (feed.Item, error) {
+	item := feed.Item{
+		Title:   postTitle(post),
+		Author:  post.Author,
+		GUID:    fmt.Sprintf("tg-%d-%d", post.Chat.ID, post.ID),
+		PubDate: post.Date.Format(time.RFC1123Z),
+		DT:      post.Date,
+	}
+	if item.Author == "" {
+		item.Author = post.Chat.Title
+	}
+	if post.Chat.Username != "" {
+		item.Link = fmt.Sprintf("https://t.me/%s/%d", post.Chat.Username, post.ID)
+	}
+
+	body := post.HTML
+	if post.Photo != nil {
+		photoURL, err := p.storeFile(name, post.Photo.Name, post.Photo.Data)
+		if err != nil {
+			return feed.Item{}, errors.Wrap(err, "can't store photo")
+		}
+		item.Thumbnail = photoURL
+		body = fmt.Sprintf(`<p><img src="%s"></p>`, photoURL) + body
+	}
+	if a := post.Audio; a != nil {
+		audioURL, err := p.storeFile(name, a.Name, a.Data)
+		if err != nil {
+			return feed.Item{}, errors.Wrap(err, "can't store audio")
+		}
+		item.Enclosure = feed.Enclosure{URL: audioURL, Length: len(a.Data), Type: a.MIME}
+		if a.Duration > 0 {
+			item.ITunesDuration = strconv.Itoa(a.Duration)
+		}
+	}
+	item.Description = template.HTML(body) // nolint
+	return item, nil
+}
+
+// postTitle returns title of post's audio, the first line of its text otherwise, channel's title for empty post
+func postTitle(post ChannelPost) string {
+	if post.Audio != nil && post.Audio.Title != "" {
+		return post.Audio.Title
+	}
+	for _, line := range strings.Split(post.Text, "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		if r := []rune(line); len(r) > maxPostTitle {
+			line = strings.TrimSpace(string(r[:maxPostTitle])) + "…"
+		}
+		return line
+	}
+	return post.Chat.Title
+}
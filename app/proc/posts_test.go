@@ -0,0 +1,120 @@
+package proc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+func TestPostFeeds(t *testing.T) {
+	conf := Conf{Feeds: map[string]Feed{
+		"news":  {TelegramSource: "@News"},
+		"copy":  {TelegramSource: "news"},
+		"byid":  {TelegramSource: "-100123"},
+		"other": {TelegramChannel: "news"},
+	}}
+
+	tbl := []struct {
+		chat *tb.Chat
+		res  []string
+	}{
+		{&tb.Chat{ID: -100555, Username: "news"}, []string{"copy", "news"}},
+		{&tb.Chat{ID: -100123, Username: "renamed"}, []string{"byid"}},
+		{&tb.Chat{ID: -100123}, []string{"byid"}},
+		{&tb.Chat{ID: -100777}, []string{}},
+		{nil, []string{}},
+	}
+	for i, tt := range tbl {
+		res := conf.postFeeds(tt.chat)
+		sort.Strings(res)
+		assert.Equal(t, tt.res, res, "case #%d", i)
+	}
+
+	p := Processor{Conf: &conf}
+	assert.True(t, p.AcceptPost(&tb.Chat{ID: -100123}))
+	assert.False(t, p.AcceptPost(&tb.Chat{ID: 1, Username: "other"}))
+}
+
+func TestDeliverPost(t *testing.T) {
+	mediaDir, err := ioutil.TempDir("", "fm-media")
+	require.NoError(t, err)
+	defer os.RemoveAll(mediaDir)
+
+	tmpfile, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmpfile.Name())
+	boltDB, err := NewBoltDB(tmpfile.Name())
+	require.NoError(t, err)
+
+	conf := &Conf{Feeds: map[string]Feed{"podcast": {TelegramSource: "@show", TelegramChannel: "mirror",
+		Tags: []TagRule{{Tag: "tg", Source: "telegram"}}}}}
+	conf.System.BaseURL = "https://fm.example.com"
+	notif := &telegramNotifMock{}
+	p := Processor{Conf: conf, Store: boltDB, TelegramNotif: notif, MediaDir: mediaDir}
+
+	chat := &tb.Chat{ID: -100123, Title: "The Show", Username: "show"}
+	dt := time.Date(2021, 7, 10, 15, 30, 0, 0, time.UTC)
+	post := ChannelPost{Chat: chat, ID: 42, Date: dt, Text: "Episode 12\nnotes", HTML: "<b>Episode 12</b><br>notes",
+		Photo: &PostFile{Name: "42-photo.jpg", MIME: "image/jpeg", Data: []byte("jpeg")},
+		Audio: &PostFile{Name: "42-ep12.mp3", MIME: "audio/mpeg", Title: "Show - Ep 12", Duration: 300, Data: []byte("mp3")},
+	}
+	require.NoError(t, p.DeliverPost(post))
+	post.HTML = "<b>Episode 12</b><br>edited notes"
+	require.NoError(t, p.DeliverPost(post), "edited post saved in place")
+	assert.EqualError(t, p.DeliverPost(ChannelPost{Chat: &tb.Chat{ID: 1, Title: "Other"}}), `no feeds for channel "Other"`)
+
+	items, err := boltDB.Load("podcast", 10, false)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(items))
+	item := items[0]
+	assert.Equal(t, "Show - Ep 12", item.Title)
+	assert.Equal(t, "The Show", item.Author)
+	assert.Equal(t, "https://t.me/show/42", item.Link)
+	assert.Equal(t, "tg--100123-42", item.GUID)
+	assert.Equal(t, dt, item.DT.UTC())
+	assert.Equal(t, []string{"tg"}, item.Tags)
+	fileURL := "https://fm.example.com/files/podcast/"
+	assert.Equal(t, fileURL+"42-photo.jpg", item.Thumbnail)
+	assert.Equal(t, `<p><img src="`+fileURL+`42-photo.jpg"></p><b>Episode 12</b><br>edited notes`, string(item.Description))
+	assert.Equal(t, fileURL+"42-ep12.mp3", item.Enclosure.URL)
+	assert.Equal(t, 3, item.Enclosure.Length)
+	assert.Equal(t, "audio/mpeg", item.Enclosure.Type)
+	assert.Equal(t, "300", item.ITunesDuration)
+	data, err := ioutil.ReadFile(filepath.Join(mediaDir, "podcast", "42-ep12.mp3"))
+	require.NoError(t, err)
+	assert.Equal(t, "mp3", string(data))
+	require.Equal(t, 1, len(notif.sent), "sent once")
+	assert.Equal(t, "mirror", notif.sent[0].channel)
+
+	p.MediaDir = ""
+	assert.EqualError(t, p.DeliverPost(post), "can't make item for podcast: can't store photo: no media directory")
+
+	p.MediaDir = mediaDir
+	require.NoError(t, boltDB.DB.Close())
+	err = p.DeliverPost(post)
+	require.Error(t, err, "failed save reported")
+	assert.Contains(t, err.Error(), "failed to save tg--100123-42")
+}
+
+func TestPostTitle(t *testing.T) {
+	chat := &tb.Chat{Title: "The Show"}
+	tbl := []struct {
+		post ChannelPost
+		res  string
+	}{
+		{ChannelPost{Chat: chat, Text: "\n  First line \nsecond", Audio: &PostFile{}}, "First line"},
+		{ChannelPost{Chat: chat, Text: "text", Audio: &PostFile{Title: "Audio"}}, "Audio"},
+		{ChannelPost{Chat: chat, Text: strings.Repeat("я", 120)}, strings.Repeat("я", 100) + "…"},
+		{ChannelPost{Chat: chat}, "The Show"},
+	}
+	for i, tt := range tbl {
+		assert.Equal(t, tt.res, postTitle(tt.post), "case #%d", i)
+	}
+}
@@ -4,14 +4,21 @@ package proc
 
 import (
 	"context"
+	"crypto/sha1" // nolint
+	"fmt"
+	"path/filepath"
 	"regexp"
+	"strings"
+	"sync"
 	"time"
 
 	log "github.com/go-pkgz/lgr"
 	"github.com/go-pkgz/syncs"
+	"github.com/pkg/errors"
 
 	"github.com/umputun/feed-master/app/feed"
 	"github.com/umputun/feed-master/app/models"
+	"github.com/umputun/feed-master/app/store"
 )
 
 // TelegramNotif is interface to send messages to telegram
@@ -21,9 +28,18 @@ type TelegramNotif interface {
 
 // Processor is a feed reader and store writer
 type Processor struct {
-	Conf        *Conf
-	Store       *BoltDB
-	TelegramBot *TelegramClientV2
+	Conf          *Conf
+	Store         *BoltDB
+	TelegramNotif TelegramNotif
+	Fetcher       *feed.Fetcher    // default fetcher used if nil
+	FeedsStore    *store.BoldStore // keeps sources validators for conditional GET, unconditional fetch if nil
+	MediaDir      string           // stored files, like attachments of received mail, served by /files
+
+	discovered   map[string]string // source page url to its feed url
+	discoveredMu sync.Mutex
+
+	dirs   map[string]*feed.Dir // directory sources by path, keep items of already read files
+	dirsMu sync.Mutex
 }
 
 // Conf for feeds config yml
@@ -41,67 +57,164 @@ type Conf struct {
 
 // Feed defines config section for a feed~
 type Feed struct {
-	Title       string `yaml:"title"`
-	Description string `yaml:"description"`
-	Link        string `yaml:"link"`
-	Image       string `yaml:"image"`
-	Language    string `yaml:"language"`
-	Filter      Filter `yaml:"filter"`
-	Sources     []struct {
-		Name string `yaml:"name"`
-		URL  string `yaml:"url"`
-	} `yaml:"sources"`
-	ExtendDateTitle string `yaml:"ext_date"`
+	Title            string   `yaml:"title"`
+	Description      string   `yaml:"description"`
+	Link             string   `yaml:"link"`
+	Image            string   `yaml:"image"`
+	Language         string   `yaml:"language"`
+	TelegramChannel  string   `yaml:"telegram_channel"`
+	TelegramChannels []string `yaml:"telegram_channels"`
+	Filter           Filter   `yaml:"filter"`
+	Sources          []Source `yaml:"sources"`
+	ExtendDateTitle  string   `yaml:"ext_date"`
+
+	// podcast metadata, for itunes elements of generated rss
+	Author      string `yaml:"author"`
+	Owner       Owner  `yaml:"owner"`
+	Category    string `yaml:"category"`
+	Subcategory string `yaml:"subcategory"`
+	Explicit    bool   `yaml:"explicit"`
+
+	// namespaces of extension elements kept from source items, "rss" for elements without namespace, "*" for all
+	Extensions []string `yaml:"extensions"`
+
+	Tags []TagRule `yaml:"tags"`
+
+	// address receiving newsletters for the feed-set, local part only or with domain
+	Email string `yaml:"email"`
+
+	// telegram channel whose posts become items of the feed-set, @username or id, the bot has to be its admin
+	TelegramSource string `yaml:"telegram_source"`
+}
+
+// TagRule defines tag added to feed items matching all set conditions, to every item if none set
+type TagRule struct {
+	Tag    string `yaml:"tag"`
+	Source string `yaml:"source"` // source name
+	Title  string `yaml:"title"`  // title regex
+}
+
+// Owner defines podcast owner of a feed
+type Owner struct {
+	Name  string `yaml:"name"`
+	Email string `yaml:"email"`
+}
+
+// Channels returns all telegram channels new items of the feed-set routed to, without duplicates
+func (f Feed) Channels() []string {
+	res := []string{}
+	seen := map[string]bool{}
+	for _, ch := range append([]string{f.TelegramChannel}, f.TelegramChannels...) {
+		ch = strings.TrimSpace(ch)
+		if ch == "" || seen[ch] {
+			continue
+		}
+		seen[ch] = true
+		res = append(res, ch)
+	}
+	return res
+}
+
+// keepExtensions returns item's extensions allowed by feed's extensions list
+func (f Feed) keepExtensions(item feed.Item) []feed.Extension {
+	allowed := map[string]bool{}
+	for _, ns := range f.Extensions {
+		ns = strings.TrimSpace(ns)
+		if ns == "rss" {
+			ns = ""
+		}
+		allowed[ns] = true
+	}
+
+	var res []feed.Extension
+	for _, ext := range item.Extensions {
+		if allowed["*"] || allowed[ext.XMLName.Space] {
+			res = append(res, ext)
+		}
+	}
+	return res
+}
+
+// Source defines a single source of a feed
+type Source struct {
+	Name      string         `yaml:"name"`
+	URL       string         `yaml:"url"`
+	Type      string         `yaml:"type"`      // kind of the source, feed if not set
+	Selectors feed.Selectors `yaml:"selectors"` // episodes of html page, for html type
+	Mapping   feed.Mapping   `yaml:"mapping"`   // items of json api response, for json type
+	Path      string         `yaml:"path"`      // directory of audio files, for directory type
+	Selector  string         `yaml:"selector"`  // watched part of page, whole page if not set, for watch type
+	Timeout   time.Duration  `yaml:"timeout"`   // overrides fetcher's timeout
+}
+
+// location returns url of the source, path for directory source
+func (s Source) location() string {
+	if s.Type == sourceDir {
+		return s.Path
+	}
+	return s.URL
 }
 
+// source types
+const (
+	sourceFeed  = "feed"      // rss, atom or json feed, or page linking to it
+	sourceHTML  = "html"      // html page scraped with css selectors
+	sourceJSON  = "json"      // json api response mapped to items
+	sourceDir   = "directory" // local directory of audio files, served by /media
+	sourceWatch = "watch"     // web page, an item with diff made on each change
+)
+
 // Filter defines feed section for a feed filter~
 type Filter struct {
 	Title string `yaml:"title"`
 }
 
-type FeedsStore interface {
-	Iterate(func(feed models.Feed) error) error
-}
-
-// Do activates loop of goroutine for each feed, concurrency limited by p.Conf.Concurrent
-func (p *Processor) Do(feedIterator FeedsStore) {
+// Do activates loop of goroutine for each source of each feed-set, concurrency limited by p.Conf.Concurrent
+func (p *Processor) Do() {
 	log.Printf("[INFO] activate processor")
 	p.setDefaults()
 
 	for {
 		swg := syncs.NewSizedGroup(p.Conf.System.Concurrent, syncs.Preemptive)
-		feedIterator.Iterate(func(f models.Feed) error {
-			swg.Go(func(context.Context) {
-				log.Printf("[INFO] fetch feed: '%s'", f.URL)
-
-				// p.feed(f, p.Conf.System.MaxItems)
-			})
-
-			return nil
-		})
-
-		// for name, fm := range p.Conf.Feeds {
-		// 	// keep up to MaxKeepInDB items in bucket
-		// 	if removed, err := p.Store.removeOld(name, p.Conf.System.MaxKeepInDB); err == nil {
-		// 		if removed > 0 {
-		// 			log.Printf("[DEBUG] removed %d from %s", removed, name)
-		// 		}
-		// 	} else {
-		// 		log.Printf("[WARN] failed to remove, %v", err)
-		// 	}
-		// }
-
+		for name, fm := range p.Conf.Feeds {
+			for _, src := range fm.Sources {
+				name, fm, src := name, fm, src
+				swg.Go(func(ctx context.Context) {
+					p.processFeed(ctx, name, fm, src, p.Conf.System.MaxItems)
+				})
+			}
+		}
 		swg.Wait()
-		log.Printf("[DEBUG] refresh completed. Next iteration after: '%v'", p.Conf.System.UpdateInterval)
 
+		for name := range p.Conf.Feeds {
+			// keep up to MaxKeepInDB items in bucket
+			if removed, err := p.Store.removeOld(name, p.Conf.System.MaxKeepInDB); err == nil {
+				if removed > 0 {
+					log.Printf("[DEBUG] removed %d from %s", removed, name)
+				}
+			} else {
+				log.Printf("[WARN] failed to remove, %v", err)
+			}
+		}
+
+		log.Printf("[DEBUG] refresh completed. Next iteration after: '%v'", p.Conf.System.UpdateInterval)
 		time.Sleep(p.Conf.System.UpdateInterval)
 	}
 }
 
-func (p *Processor) feed(f Feed, max int) {
-	rss, err := feed.Parse(f.Link)
+// processFeed fetches a single source and saves up to max items to feed-set bucket, sends new items to telegram
+func (p *Processor) processFeed(ctx context.Context, name string, fm Feed, src Source, max int) {
+	log.Printf("[DEBUG] fetch feed %s, source %q: '%s'", name, src.Name, src.location())
+	validators := p.loadValidators(name, src)
+	opts := feed.ParseOpts{Fetcher: p.Fetcher, Timeout: src.Timeout, Validators: validators}
+	rss, snapshot, err := p.parseSource(ctx, name, src, opts)
+	if errors.Is(err, feed.ErrNotModified) {
+		log.Printf("[DEBUG] feed %s, source %q not modified", name, src.Name)
+		p.saveValidators(name, src, validators)
+		return
+	}
 	if err != nil {
-		log.Printf("[WARN] failed to parse %s, %v", f.Link, err)
+		log.Printf("[WARN] failed to parse %s, %v", src.location(), err)
 		return
 	}
 
@@ -111,45 +224,284 @@ func (p *Processor) feed(f Feed, max int) {
 		upto = len(rss.ItemList)
 	}
 
+	saved := true
 	for _, item := range rss.ItemList[:upto] {
 		// skip 1y and older
-		if item.DT.Before(time.Now().AddDate(-1, 0, 0)) {
+		if !item.DT.IsZero() && item.DT.Before(time.Now().AddDate(-1, 0, 0)) {
 			continue
 		}
+		item.Extensions = fm.keepExtensions(item)
+		if err := p.saveItem(name, fm, src, item); err != nil {
+			log.Printf("[WARN] %v", err)
+			saved = false
+		}
+	}
 
-		name := f.Title
-		created, err := p.Store.Save(name, item)
+	// validators and snapshot of content failed to parse or to save are not saved, to retry it next time
+	if saved {
+		p.saveValidators(name, src, validators)
+		p.saveSnapshot(snapshot)
+	}
+}
+
+// saveItem applies tag rules and filter of feed-set to the item of the source, saves it
+// and sends to telegram if the item is new.
+func (p *Processor) saveItem(name string, fm Feed, src Source, item feed.Item) error {
+	for _, r := range fm.Tags {
+		matched, err := r.match(src, item)
 		if err != nil {
-			log.Printf("[WARN] failed to save %s (%s) to %s, %v", item.GUID, item.PubDate, name, err)
+			log.Printf("[WARN] failed to match tag rule %q for %s, %v", r.Tag, item.GUID, err)
+		}
+		if matched {
+			item.AddTags(r.Tag)
+		}
+	}
+
+	skip, err := fm.Filter.skip(item)
+	if err != nil {
+		log.Printf("[WARN] failed to filter %s (%s) to %s, save as is, %v", item.GUID, item.PubDate, name, err)
+	}
+	if skip {
+		item.Junk = true
+		log.Printf("[INFO] filtered %s (%s), %s %s", item.GUID, item.PubDate, name, item.Title)
+	}
+
+	created, err := p.Store.Save(name, item)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save %s (%s) to %s", item.GUID, item.PubDate, name)
+	}
+
+	// items checked one by one, a known item doesn't mean the rest is known as sources may be out of order
+	if !created || item.Junk {
+		return nil
+	}
+	p.notify(fm, item)
+	return nil
+}
+
+// parseSource gets normalized items of the feed-set's source, by the source's type.
+// Watch source also returns the page snapshot, to be saved after the items.
+func (p *Processor) parseSource(ctx context.Context, name string, src Source, opts feed.ParseOpts) (feed.Rss2, *models.Snapshot, error) {
+	var rss feed.Rss2
+	var err error
+	switch src.Type {
+	case sourceHTML:
+		rss, err = feed.Scrape(ctx, src.URL, src.Selectors, opts)
+	case sourceJSON:
+		rss, err = feed.ParseJSONAPI(ctx, src.URL, src.Mapping, opts)
+	case sourceDir:
+		rss, err = p.dir(src).Parse()
+	case sourceWatch:
+		return p.watch(ctx, name, src, opts)
+	default:
+		rss, err = feed.ParseWithOptions(ctx, p.feedURL(src), opts)
+		if errors.Is(err, feed.ErrHTMLPage) {
+			var feedURL string
+			if feedURL, err = p.discover(ctx, src); err == nil {
+				rss, err = feed.ParseWithOptions(ctx, feedURL, opts)
+			}
 		}
+	}
+	return rss, nil, err
+}
+
+// dir returns reader of the directory source, made on the first call
+func (p *Processor) dir(src Source) *feed.Dir {
+	p.dirsMu.Lock()
+	defer p.dirsMu.Unlock()
+	if d, ok := p.dirs[src.Path]; ok {
+		return d
+	}
+	if p.dirs == nil {
+		p.dirs = map[string]*feed.Dir{}
+	}
+	d := &feed.Dir{Path: src.Path, BaseURL: p.Conf.System.BaseURL + "/media/" + MediaID(src.Path)}
+	p.dirs[src.Path] = d
+	return d
+}
 
-		if !created {
-			return
+// MediaID returns id of the directory in /media urls
+func MediaID(dir string) string {
+	return fmt.Sprintf("%x", sha1.Sum([]byte(filepath.Clean(dir))))[:12] // nolint
+}
+
+// MediaDir returns directory of directory source by its media id
+func (c *Conf) MediaDir(id string) (string, bool) {
+	for _, f := range c.Feeds {
+		for _, src := range f.Sources {
+			if src.Type == sourceDir && MediaID(src.Path) == id {
+				return src.Path, true
+			}
 		}
+	}
+	return "", false
+}
+
+// feedURL returns url of the source's feed, discovered one if source url is html page
+func (p *Processor) feedURL(src Source) string {
+	p.discoveredMu.Lock()
+	defer p.discoveredMu.Unlock()
+	if u, ok := p.discovered[src.URL]; ok {
+		return u
+	}
+	return src.URL
+}
+
+// discover finds feed of the source's html page, the first one if page links several feeds
+func (p *Processor) discover(ctx context.Context, src Source) (string, error) {
+	candidates, err := feed.Discover(ctx, src.URL, p.Fetcher)
+	if err != nil {
+		return "", errors.Wrapf(err, "can't discover feed of %s", src.URL)
+	}
+	log.Printf("[INFO] discovered feed %s (%s) for source %q, %s", candidates[0].URL, candidates[0].Title, src.Name, src.URL)
+
+	p.discoveredMu.Lock()
+	defer p.discoveredMu.Unlock()
+	if p.discovered == nil {
+		p.discovered = map[string]string{}
+	}
+	p.discovered[src.URL] = candidates[0].URL
+	return candidates[0].URL, nil
+}
+
+// loadValidators returns stored validators of the feed-set's source, nil if FeedsStore not set or source is not fetched.
+// Validators kept per feed-set, so a source shared by feed-sets is not reported as not modified to the second one.
+func (p *Processor) loadValidators(name string, src Source) *feed.Validators {
+	if p.FeedsStore == nil || src.Type == sourceDir {
+		return nil
+	}
+	f, _, err := p.FeedsStore.Load(name, src.URL)
+	if err != nil {
+		log.Printf("[WARN] failed to load validators of %s, %v", src.URL, err)
+	}
+	return &feed.Validators{ETag: f.ETag, LastModified: f.LastModified, ContentHash: f.ContentHash}
+}
 
-		// if err := p.TelegramNotif.Send("telegramChannel", item); err != nil {
-		// 	log.Printf("[WARN] failed to send telegram message, url=%s to channel=%s, %v",
-		// 		item.Enclosure.URL, "telegramChannel", err)
-		// }
+// saveValidators stores validators of the feed-set's source if changed
+func (p *Processor) saveValidators(name string, src Source, v *feed.Validators) {
+	if p.FeedsStore == nil || v == nil {
+		return
+	}
+	f, _, err := p.FeedsStore.Load(name, src.URL)
+	if err != nil {
+		log.Printf("[WARN] failed to load %s, %v", src.URL, err)
+	}
+	upd := models.Feed{Title: f.Title, URL: src.URL, FeedSet: name, ETag: v.ETag, LastModified: v.LastModified, ContentHash: v.ContentHash}
+	if upd == f {
+		return
+	}
+	if _, err := p.FeedsStore.Save(upd); err != nil {
+		log.Printf("[WARN] failed to save validators of %s, %v", src.URL, err)
+	}
+}
+
+// notify sends item to all telegram channels of the feed-set
+func (p *Processor) notify(fm Feed, item feed.Item) {
+	if p.TelegramNotif == nil {
+		return
+	}
+	for _, ch := range fm.Channels() {
+		if err := p.TelegramNotif.Send(ch, item); err != nil {
+			log.Printf("[WARN] failed to send telegram message, url=%s to channel=%s, %v", item.Enclosure.URL, ch, err)
+		}
 	}
 }
 
 func (p *Processor) setDefaults() {
-	if p.Conf.System.Concurrent == 0 {
-		p.Conf.System.Concurrent = 8
+	p.Conf.SetDefaults()
+}
+
+// SetDefaults fills unset system parameters with default values
+func (c *Conf) SetDefaults() {
+	if c.System.Concurrent == 0 {
+		c.System.Concurrent = 8
 	}
-	if p.Conf.System.MaxItems == 0 {
-		p.Conf.System.MaxItems = 5
+	if c.System.MaxItems == 0 {
+		c.System.MaxItems = 5
 	}
-	if p.Conf.System.MaxTotal == 0 {
-		p.Conf.System.MaxTotal = 100
+	if c.System.MaxTotal == 0 {
+		c.System.MaxTotal = 100
 	}
-	if p.Conf.System.MaxKeepInDB == 0 {
-		p.Conf.System.MaxKeepInDB = 5000
+	if c.System.MaxKeepInDB == 0 {
+		c.System.MaxKeepInDB = 5000
+	}
+	if c.System.UpdateInterval == 0 {
+		c.System.UpdateInterval = time.Minute * 5
+	}
+}
+
+// Validate checks config for missing or malformed values
+func (c *Conf) Validate() error {
+	if len(c.Feeds) == 0 {
+		return errors.New("no feeds defined")
+	}
+	for name, f := range c.Feeds {
+		if len(f.Sources) == 0 && f.Email == "" && f.TelegramSource == "" {
+			return errors.Errorf("no sources defined for feed %q", name)
+		}
+		for _, ch := range f.Channels() {
+			if f.TelegramSource != "" && strings.EqualFold(strings.TrimPrefix(ch, "@"), strings.TrimPrefix(f.TelegramSource, "@")) {
+				return errors.Errorf("telegram_source of feed %q is its telegram channel too", name)
+			}
+		}
+		for i, src := range f.Sources {
+			if src.URL == "" && src.Type != sourceDir {
+				return errors.Errorf("empty url for source #%d of feed %q", i, name)
+			}
+			switch src.Type {
+			case "", sourceFeed:
+			case sourceHTML:
+				if err := src.Selectors.Validate(); err != nil {
+					return errors.Wrapf(err, "invalid selectors for source #%d of feed %q", i, name)
+				}
+			case sourceJSON:
+				if err := src.Mapping.Validate(); err != nil {
+					return errors.Wrapf(err, "invalid mapping for source #%d of feed %q", i, name)
+				}
+			case sourceDir:
+				if src.Path == "" {
+					return errors.Errorf("empty path for source #%d of feed %q", i, name)
+				}
+				if c.System.BaseURL == "" {
+					return errors.Errorf("base_url required for directory source #%d of feed %q", i, name)
+				}
+			case sourceWatch:
+				if err := feed.ValidateSelector(src.Selector); err != nil {
+					return errors.Wrapf(err, "invalid source #%d of feed %q", i, name)
+				}
+			default:
+				return errors.Errorf("unknown type %q of source #%d of feed %q", src.Type, i, name)
+			}
+		}
+		if f.Filter.Title != "" {
+			if _, err := regexp.Compile(f.Filter.Title); err != nil {
+				return errors.Wrapf(err, "invalid title filter for feed %q", name)
+			}
+		}
+		for i, r := range f.Tags {
+			if strings.TrimSpace(r.Tag) == "" {
+				return errors.Errorf("empty tag in rule #%d of feed %q", i, name)
+			}
+			if _, err := regexp.Compile(r.Title); err != nil {
+				return errors.Wrapf(err, "invalid title in tag rule #%d of feed %q", i, name)
+			}
+		}
+	}
+	if c.System.UpdateInterval < 0 {
+		return errors.Errorf("negative update interval %v", c.System.UpdateInterval)
+	}
+	return nil
+}
+
+// match checks if item of the source matches the rule
+func (r TagRule) match(src Source, item feed.Item) (bool, error) {
+	if r.Source != "" && r.Source != src.Name {
+		return false, nil
 	}
-	if p.Conf.System.UpdateInterval == 0 {
-		p.Conf.System.UpdateInterval = time.Minute * 5
+	if r.Title != "" {
+		return regexp.MatchString(r.Title, item.Title)
 	}
+	return true, nil
 }
 
 func (filter *Filter) skip(item feed.Item) (bool, error) {
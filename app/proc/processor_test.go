@@ -1,13 +1,26 @@
 package proc
 
 import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"regexp/syntax"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
 	"github.com/umputun/feed-master/app/feed"
+	"github.com/umputun/feed-master/app/store"
 )
 
 func TestSetDefault(t *testing.T) {
@@ -67,3 +80,517 @@ func TestFilterAllCases(t *testing.T) {
 		})
 	}
 }
+
+func TestConfValidate(t *testing.T) {
+	tbl := []struct {
+		conf Conf
+		err  string
+	}{
+		{Conf{}, "no feeds defined"},
+		{Conf{Feeds: map[string]Feed{"f1": {}}}, `no sources defined for feed "f1"`},
+		{Conf{Feeds: map[string]Feed{"f1": {Sources: []Source{{Name: "s1"}}}}}, `empty url for source #0 of feed "f1"`},
+		{Conf{Feeds: map[string]Feed{"f1": {Sources: []Source{{URL: "http://example.com"}}, Filter: Filter{Title: "("}}}},
+			"invalid title filter for feed \"f1\": error parsing regexp: missing closing ): `(`"},
+		{Conf{Feeds: map[string]Feed{"f1": {Sources: []Source{{URL: "http://example.com"}}, Tags: []TagRule{{Source: "s1"}}}}},
+			`empty tag in rule #0 of feed "f1"`},
+		{Conf{Feeds: map[string]Feed{"f1": {Sources: []Source{{URL: "http://example.com"}}, Tags: []TagRule{{Tag: "t", Title: "["}}}}},
+			"invalid title in tag rule #0 of feed \"f1\": error parsing regexp: missing closing ]: `[`"},
+		{Conf{Feeds: map[string]Feed{"f1": {Sources: []Source{{URL: "http://example.com", Type: "nntp"}}}}},
+			`unknown type "nntp" of source #0 of feed "f1"`},
+		{Conf{Feeds: map[string]Feed{"f1": {Sources: []Source{{URL: "http://example.com", Type: "html",
+			Selectors: feed.Selectors{Item: ".episode"}}}}}}, `invalid selectors for source #0 of feed "f1": empty title selector`},
+		{Conf{Feeds: map[string]Feed{"f1": {Sources: []Source{{URL: "http://example.com", Type: "html",
+			Selectors: feed.Selectors{Item: ".episode", Title: "h2", Date: "time["}}}}}},
+			`invalid selectors for source #0 of feed "f1": invalid date selector "time[": expected identifier, found EOF instead`},
+		{Conf{Feeds: map[string]Feed{"f1": {Sources: []Source{{URL: "http://example.com", Type: "html",
+			Selectors: feed.Selectors{Item: ".episode", Title: "h2"}}}}}}, ""},
+		{Conf{Feeds: map[string]Feed{"f1": {Sources: []Source{{URL: "http://example.com", Type: "json",
+			Mapping: feed.Mapping{Title: "title", Date: "dates[x]"}}}}}},
+			`invalid mapping for source #0 of feed "f1": invalid date path "dates[x]": invalid index "x"`},
+		{Conf{Feeds: map[string]Feed{"f1": {Sources: []Source{{Type: "directory"}}}}}, `empty path for source #0 of feed "f1"`},
+		{Conf{Feeds: map[string]Feed{"f1": {Sources: []Source{{Type: "directory", Path: "/srv/podcast"}}}}},
+			`base_url required for directory source #0 of feed "f1"`},
+		{Conf{Feeds: map[string]Feed{"f1": {Sources: []Source{{URL: "http://example.com", Type: "watch", Selector: "div["}}}}},
+			`invalid source #0 of feed "f1": invalid selector "div[": expected identifier, found EOF instead`},
+		{Conf{Feeds: map[string]Feed{"f1": {TelegramSource: "@news", TelegramChannels: []string{"News"}}}},
+			`telegram_source of feed "f1" is its telegram channel too`},
+		{Conf{Feeds: map[string]Feed{"f1": {Email: "weekly"}}}, ""},
+		{Conf{Feeds: map[string]Feed{"f1": {TelegramSource: "@news", TelegramChannel: "mirror"}}}, ""},
+		{Conf{Feeds: map[string]Feed{"f1": {Sources: []Source{{URL: "http://example.com"}}}}}, ""},
+	}
+
+	for i, tt := range tbl {
+		tt := tt
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			err := tt.conf.Validate()
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestProcessFeed(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pubDate := time.Now().Add(-time.Hour).Format(time.RFC1123Z)
+		_, _ = fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+  <channel>
+    <title>test feed</title>
+    <item><title>Title 1</title><guid>g1</guid><pubDate>%s</pubDate></item>
+    <item><title>Title (Part 2)</title><guid>g2</guid><pubDate>%s</pubDate></item>
+    <item><title>Title 3</title><guid>g3</guid><pubDate>%s</pubDate></item>
+  </channel>
+</rss>`, pubDate, pubDate, pubDate)
+	}))
+	defer ts.Close()
+
+	tmpfile, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmpfile.Name())
+	boltDB, err := NewBoltDB(tmpfile.Name())
+	require.NoError(t, err)
+
+	notif := &telegramNotifMock{}
+	p := Processor{Conf: &Conf{}, Store: boltDB, TelegramNotif: notif}
+	fm := Feed{TelegramChannel: "chan", Filter: Filter{Title: `\(Part \d+\)`}, Sources: []Source{{Name: "src", URL: ts.URL}}}
+
+	p.processFeed(context.Background(), "fs", fm, fm.Sources[0], 2)
+
+	items, err := boltDB.Load("fs", 10, false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(items), "only max items saved")
+
+	items, err = boltDB.Load("fs", 10, true)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(items), "junk skipped")
+	assert.Equal(t, "Title 1", items[0].Title)
+
+	require.Equal(t, 1, len(notif.sent), "junk not sent")
+	assert.Equal(t, "chan", notif.sent[0].channel)
+	assert.Equal(t, "g1", notif.sent[0].item.GUID)
+
+	p.processFeed(context.Background(), "fs", fm, fm.Sources[0], 2)
+	assert.Equal(t, 1, len(notif.sent), "nothing new sent")
+}
+
+func TestProcessFeedConditional(t *testing.T) {
+	var fetched, notModified int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		atomic.AddInt32(&fetched, 1)
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = fmt.Fprintf(w, `<rss version="2.0"><channel><title>test feed</title>
+			<item><title>Title 1</title><guid>g1</guid><pubDate>%s</pubDate></item></channel></rss>`,
+			time.Now().Format(time.RFC1123Z))
+	}))
+	defer ts.Close()
+
+	tmpfile, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmpfile.Name())
+	boltDB, err := NewBoltDB(tmpfile.Name())
+	require.NoError(t, err)
+	feedsStore := &store.BoldStore{DB: boltDB.DB}
+
+	notif := &telegramNotifMock{}
+	p := Processor{Conf: &Conf{}, Store: boltDB, TelegramNotif: notif, FeedsStore: feedsStore}
+	fm := Feed{TelegramChannel: "chan", Sources: []Source{{Name: "src", URL: ts.URL}}}
+
+	p.processFeed(context.Background(), "fs", fm, fm.Sources[0], 5)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetched))
+	assert.Equal(t, 1, len(notif.sent))
+
+	stored, found, err := feedsStore.Load("fs", ts.URL)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, `"v1"`, stored.ETag)
+	assert.NotEmpty(t, stored.ContentHash)
+
+	p.processFeed(context.Background(), "fs", fm, fm.Sources[0], 5)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetched), "not fetched again")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&notModified))
+	assert.Equal(t, 1, len(notif.sent))
+
+	// the same source in another feed-set has its own validators
+	p.processFeed(context.Background(), "fs2", fm, fm.Sources[0], 5)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fetched), "fetched for another feed-set")
+	items, err := boltDB.Load("fs2", 10, true)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(items))
+	assert.Equal(t, "g1", items[0].GUID)
+}
+
+func TestProcessFeedValidatorsNotSavedOnFailure(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = fmt.Fprintf(w, `<rss version="2.0"><channel><title>test feed</title>
+			<item><pubDate>%s</pubDate></item></channel></rss>`, time.Now().Format(time.RFC1123Z))
+	}))
+	defer ts.Close()
+
+	tmpfile, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmpfile.Name())
+	boltDB, err := NewBoltDB(tmpfile.Name())
+	require.NoError(t, err)
+	feedsStore := &store.BoldStore{DB: boltDB.DB}
+
+	p := Processor{Conf: &Conf{}, Store: boltDB, FeedsStore: feedsStore}
+	fm := Feed{Sources: []Source{{Name: "src", URL: ts.URL}}}
+	p.processFeed(context.Background(), "fs", fm, fm.Sources[0], 5)
+
+	_, found, err := feedsStore.Load("fs", ts.URL)
+	require.NoError(t, err)
+	assert.False(t, found, "item without identity not saved, validators not saved to retry")
+}
+
+func TestProcessFeedDiscover(t *testing.T) {
+	var pages int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/rss" {
+			_, _ = fmt.Fprintf(w, `<rss version="2.0"><channel><title>test feed</title>
+			<item><title>Title 1</title><guid>g1</guid><pubDate>%s</pubDate></item></channel></rss>`,
+				time.Now().Format(time.RFC1123Z))
+			return
+		}
+		atomic.AddInt32(&pages, 1)
+		_, _ = w.Write([]byte(`<html><head><link rel="alternate" type="application/rss+xml" href="/rss"></head></html>`))
+	}))
+	defer ts.Close()
+
+	tmpfile, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmpfile.Name())
+	boltDB, err := NewBoltDB(tmpfile.Name())
+	require.NoError(t, err)
+
+	p := Processor{Conf: &Conf{}, Store: boltDB, TelegramNotif: &telegramNotifMock{}}
+	fm := Feed{Sources: []Source{{Name: "src", URL: ts.URL + "/blog"}}}
+
+	p.processFeed(context.Background(), "fs", fm, fm.Sources[0], 5)
+	items, err := boltDB.Load("fs", 10, true)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(items))
+	assert.Equal(t, "Title 1", items[0].Title)
+	assert.Equal(t, ts.URL+"/rss", p.feedURL(fm.Sources[0]))
+
+	fetchedPages := atomic.LoadInt32(&pages)
+	p.processFeed(context.Background(), "fs", fm, fm.Sources[0], 5)
+	assert.Equal(t, fetchedPages, atomic.LoadInt32(&pages), "discovered feed url reused")
+}
+
+func TestProcessFeedScrape(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `<html><body><div class="ep"><h3>Episode 1</h3><a href="/ep1.mp3">mp3</a>
+			<time datetime="%s"></time></div></body></html>`, time.Now().Format(time.RFC3339))
+	}))
+	defer ts.Close()
+
+	tmpfile, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmpfile.Name())
+	boltDB, err := NewBoltDB(tmpfile.Name())
+	require.NoError(t, err)
+
+	p := Processor{Conf: &Conf{}, Store: boltDB, TelegramNotif: &telegramNotifMock{}}
+	src := Source{Name: "show", URL: ts.URL, Type: "html", Selectors: feed.Selectors{Item: ".ep", Title: "h3", Date: "time"}}
+	fm := Feed{Sources: []Source{src}}
+
+	p.processFeed(context.Background(), "fs", fm, src, 5)
+	items, err := boltDB.Load("fs", 10, true)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(items))
+	assert.Equal(t, "Episode 1", items[0].Title)
+	assert.Equal(t, ts.URL+"/ep1.mp3", items[0].Enclosure.URL)
+}
+
+func TestProcessFeedJSONAPI(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"data": {"posts": [{"id": 12, "name": "Release 1.2", "url": "/releases/12", "ts": %d}]}}`,
+			time.Now().Unix())
+	}))
+	defer ts.Close()
+
+	tmpfile, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmpfile.Name())
+	boltDB, err := NewBoltDB(tmpfile.Name())
+	require.NoError(t, err)
+
+	notif := &telegramNotifMock{}
+	p := Processor{Conf: &Conf{}, Store: boltDB, TelegramNotif: notif}
+	src := Source{Name: "releases", URL: ts.URL, Type: "json",
+		Mapping: feed.Mapping{Items: "$.data.posts", Title: "name", Link: "url", GUID: "id", Date: "ts"}}
+	fm := Feed{TelegramChannel: "chan", Sources: []Source{src}}
+
+	p.processFeed(context.Background(), "fs", fm, src, 5)
+	items, err := boltDB.Load("fs", 10, true)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(items))
+	assert.Equal(t, "Release 1.2", items[0].Title)
+	assert.Equal(t, ts.URL+"/releases/12", items[0].Link)
+	assert.Equal(t, "12", items[0].GUID)
+	assert.Equal(t, 1, len(notif.sent))
+}
+
+func TestProcessFeedDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fm-media")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tmpfile, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmpfile.Name())
+	boltDB, err := NewBoltDB(tmpfile.Name())
+	require.NoError(t, err)
+
+	conf := &Conf{}
+	conf.System.BaseURL = "https://fm.example.com"
+	src := Source{Name: "own", Type: "directory", Path: dir}
+	conf.Feeds = map[string]Feed{"fs": {Sources: []Source{src}}}
+	p := Processor{Conf: conf, Store: boltDB, TelegramNotif: &telegramNotifMock{}}
+
+	p.processFeed(context.Background(), "fs", conf.Feeds["fs"], src, 5)
+	_, err = boltDB.Load("fs", 10, true)
+	require.EqualError(t, err, "no bucket for fs", "nothing saved for empty directory")
+
+	// new file picked up on the next run
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "ep1.mp3"), []byte("audio"), 0o600))
+	p.processFeed(context.Background(), "fs", conf.Feeds["fs"], src, 5)
+	items, err := boltDB.Load("fs", 10, true)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(items))
+	assert.Equal(t, "ep1", items[0].Title)
+	assert.Equal(t, "https://fm.example.com/media/"+MediaID(dir)+"/ep1.mp3", items[0].Enclosure.URL)
+
+	mediaDir, ok := conf.MediaDir(MediaID(dir))
+	assert.True(t, ok)
+	assert.Equal(t, dir, mediaDir)
+	_, ok = conf.MediaDir("unknown")
+	assert.False(t, ok)
+}
+
+func TestProcessFeedWatch(t *testing.T) {
+	var mu sync.Mutex
+	page := `<html><head><title>Schedule</title></head><body><ul id="s"><li>Monday: live</li></ul><p>at 12:00</p></body></html>`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		_, _ = w.Write([]byte(page))
+	}))
+	defer ts.Close()
+	setPage := func(s string) {
+		mu.Lock()
+		page = s
+		mu.Unlock()
+	}
+
+	tmpfile, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmpfile.Name())
+	boltDB, err := NewBoltDB(tmpfile.Name())
+	require.NoError(t, err)
+
+	notif := &telegramNotifMock{}
+	p := Processor{Conf: &Conf{}, Store: boltDB, TelegramNotif: notif, FeedsStore: &store.BoldStore{DB: boltDB.DB}}
+	src := Source{Name: "schedule", URL: ts.URL, Type: "watch", Selector: "#s"}
+	fm := Feed{TelegramChannel: "chan", Sources: []Source{src}}
+
+	p.processFeed(context.Background(), "fs", fm, src, 5)
+	_, err = boltDB.Load("fs", 10, true)
+	require.EqualError(t, err, "no bucket for fs", "the first snapshot makes no item")
+
+	setPage(`<html><head><title>Schedule</title></head><body><ul id="s"><li>Monday: live</li></ul><p>at 12:05</p></body></html>`)
+	p.processFeed(context.Background(), "fs", fm, src, 5)
+	_, err = boltDB.Load("fs", 10, true)
+	require.EqualError(t, err, "no bucket for fs", "change outside of selector ignored")
+
+	setPage(`<html><head><title>Schedule</title></head><body><ul id="s"><li>Monday: rerun</li><li>Friday: live</li></ul></body></html>`)
+	p.processFeed(context.Background(), "fs", fm, src, 5)
+	p.processFeed(context.Background(), "fs", fm, src, 5)
+	items, err := boltDB.Load("fs", 10, true)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(items))
+	assert.Equal(t, "Schedule changed", items[0].Title)
+	assert.Equal(t, ts.URL, items[0].Link)
+	assert.Equal(t, "<pre>- Monday: live\n+ Monday: rerun\n+ Friday: live</pre>", string(items[0].Description))
+	assert.False(t, items[0].DT.IsZero())
+	require.Equal(t, 1, len(notif.sent))
+
+	snap, found, err := p.FeedsStore.LoadSnapshot("fs " + ts.URL + " #s")
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, "Monday: rerun\nFriday: live", snap.Text)
+
+	// another feed-set watching the same page has its own snapshot
+	p.processFeed(context.Background(), "fs2", fm, src, 5)
+	_, err = boltDB.Load("fs2", 10, true)
+	require.EqualError(t, err, "no bucket for fs2", "the first snapshot of another feed-set makes no item")
+	setPage(`<html><head><title>Schedule</title></head><body><ul id="s"><li>Friday: live</li></ul></body></html>`)
+	p.processFeed(context.Background(), "fs", fm, src, 5)
+	p.processFeed(context.Background(), "fs2", fm, src, 5)
+	items, err = boltDB.Load("fs", 10, true)
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(items))
+	items, err = boltDB.Load("fs2", 10, true)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(items), "change item made for both feed-sets")
+	assert.Equal(t, "<pre>- Monday: rerun</pre>", string(items[0].Description))
+
+	_, _, err = (&Processor{Conf: &Conf{}}).watch(context.Background(), "fs", src, feed.ParseOpts{})
+	assert.EqualError(t, err, "no store for snapshots")
+}
+
+func TestProcessFeedTags(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pubDate := time.Now().Add(-time.Hour).Format(time.RFC1123Z)
+		_, _ = fmt.Fprintf(w, `<rss version="2.0"><channel><title>test feed</title>
+			<item><title>Interview 1</title><guid>g1</guid><pubDate>%s</pubDate><category>Politics</category></item>
+			<item><title>Title 2</title><guid>g2</guid><pubDate>%s</pubDate><category> politics </category><category>Culture</category></item>
+			</channel></rss>`, pubDate, pubDate)
+	}))
+	defer ts.Close()
+
+	tmpfile, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmpfile.Name())
+	boltDB, err := NewBoltDB(tmpfile.Name())
+	require.NoError(t, err)
+
+	p := Processor{Conf: &Conf{}, Store: boltDB}
+	fm := Feed{
+		Sources: []Source{{Name: "src1", URL: ts.URL}, {Name: "src2", URL: ts.URL + "/other"}},
+		Tags: []TagRule{
+			{Tag: "news", Source: "src1"},
+			{Tag: "interview", Title: "^Interview"},
+			{Tag: "other", Source: "src2"},
+			{Tag: "all"},
+		},
+	}
+	p.processFeed(context.Background(), "fs", fm, fm.Sources[0], 5)
+
+	items, err := boltDB.Load("fs", 10, false)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(items))
+	tags := map[string][]string{}
+	for _, item := range items {
+		tags[item.GUID] = item.Tags
+	}
+	assert.Equal(t, []string{"Politics", "news", "interview", "all"}, tags["g1"])
+	assert.Equal(t, []string{"politics", "Culture", "news", "all"}, tags["g2"])
+
+	items, err = boltDB.LoadTagged("fs", 10, false, "INTERVIEW")
+	require.NoError(t, err)
+	require.Equal(t, 1, len(items))
+	assert.Equal(t, "g1", items[0].GUID)
+
+	items, err = boltDB.LoadTagged("fs", 10, false, "other")
+	require.NoError(t, err)
+	assert.Equal(t, 0, len(items))
+}
+
+type telegramNotifMock struct {
+	sent []struct {
+		channel string
+		item    feed.Item
+	}
+}
+
+func (m *telegramNotifMock) Send(chanID string, item feed.Item) error {
+	m.sent = append(m.sent, struct {
+		channel string
+		item    feed.Item
+	}{chanID, item})
+	return nil
+}
+
+func TestFeedChannels(t *testing.T) {
+	tbl := []struct {
+		feed Feed
+		res  []string
+	}{
+		{Feed{}, []string{}},
+		{Feed{TelegramChannel: "ch1"}, []string{"ch1"}},
+		{Feed{TelegramChannels: []string{"ch1", "ch2"}}, []string{"ch1", "ch2"}},
+		{Feed{TelegramChannel: "ch1", TelegramChannels: []string{"ch2", "ch1", " ", "ch3"}}, []string{"ch1", "ch2", "ch3"}},
+	}
+
+	for i, tt := range tbl {
+		tt := tt
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			assert.Equal(t, tt.res, tt.feed.Channels())
+		})
+	}
+}
+
+func TestFeedKeepExtensions(t *testing.T) {
+	item := feed.Item{Extensions: []feed.Extension{
+		{XMLName: xml.Name{Local: "category"}},
+		{XMLName: xml.Name{Space: "http://purl.org/dc/elements/1.1/", Local: "creator"}},
+		{XMLName: xml.Name{Space: "urn:custom", Local: "field"}},
+	}}
+
+	tbl := []struct {
+		extensions []string
+		res        []string
+	}{
+		{nil, nil},
+		{[]string{"rss"}, []string{"category"}},
+		{[]string{"http://purl.org/dc/elements/1.1/", "urn:custom"}, []string{"creator", "field"}},
+		{[]string{"*"}, []string{"category", "creator", "field"}},
+	}
+
+	for i, tt := range tbl {
+		tt := tt
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			var res []string
+			for _, ext := range (Feed{Extensions: tt.extensions}).keepExtensions(item) {
+				res = append(res, ext.XMLName.Local)
+			}
+			assert.Equal(t, tt.res, res)
+		})
+	}
+}
+
+func TestNotify(t *testing.T) {
+	notif := &telegramNotifMock{}
+	p := Processor{Conf: &Conf{}, TelegramNotif: notif}
+	p.notify(Feed{TelegramChannel: "ch1", TelegramChannels: []string{"ch2"}}, feed.Item{GUID: "g1"})
+	p.notify(Feed{}, feed.Item{GUID: "g2"})
+
+	require.Equal(t, 2, len(notif.sent))
+	assert.Equal(t, "ch1", notif.sent[0].channel)
+	assert.Equal(t, "ch2", notif.sent[1].channel)
+	assert.Equal(t, "g1", notif.sent[1].item.GUID)
+
+	p = Processor{Conf: &Conf{}}
+	p.notify(Feed{TelegramChannel: "ch1"}, feed.Item{GUID: "g1"}) // no panic without notifier
+}
+
+func TestProcessFeedOutOfOrder(t *testing.T) {
+	guids := []string{"g2"}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><rss version="2.0"><channel><title>test feed</title>`)
+		for i, g := range guids {
+			pubDate := time.Now().Add(-time.Duration(i+1) * time.Hour).Format(time.RFC1123Z)
+			_, _ = fmt.Fprintf(w, `<item><title>%s</title><guid>%s</guid><pubDate>%s</pubDate></item>`, g, g, pubDate)
+		}
+		_, _ = fmt.Fprint(w, `</channel></rss>`)
+	}))
+	defer ts.Close()
+
+	tmpfile, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmpfile.Name())
+	boltDB, err := NewBoltDB(tmpfile.Name())
+	require.NoError(t, err)
+
+	notif := &telegramNotifMock{}
+	p := Processor{Conf: &Conf{}, Store: boltDB, TelegramNotif: notif}
+	fm := Feed{TelegramChannel: "chan", Sources: []Source{{Name: "src", URL: ts.URL}}}
+
+	p.processFeed(context.Background(), "fs", fm, fm.Sources[0], 5)
+	require.Equal(t, 1, len(notif.sent))
+
+	guids = []string{"g2", "g1"} // back-dated item added after the known one
+	p.processFeed(context.Background(), "fs", fm, fm.Sources[0], 5)
+	require.Equal(t, 2, len(notif.sent))
+	assert.Equal(t, "g1", notif.sent[1].item.GUID)
+}
@@ -0,0 +1,72 @@
+package proc
+
+import (
+	"fmt"
+	"html/template"
+	"strconv"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/pkg/errors"
+
+	"github.com/umputun/feed-master/app/feed"
+)
+
+// publishSource is the pseudo source of items uploaded to the bot, for tag rules
+var publishSource = Source{Name: "upload"}
+
+// Publish stores audio uploaded to the bot in MediaDir and saves it as item of the chosen feed-set,
+// of the only feed-set if not chosen. Returns name of the feed-set.
+func (p *Processor) Publish(up Upload) (string, error) {
+	name, err := p.Conf.publishFeed(up.Feed)
+	if err != nil {
+		return "", err
+	}
+	if up.Open == nil {
+		return "", errors.New("no file")
+	}
+
+	rd, err := up.Open()
+	if err != nil {
+		return "", errors.Wrapf(err, "can't download %s", up.Name)
+	}
+	defer rd.Close() // nolint
+	fileURL, size, err := p.storeStream(name, fmt.Sprintf("%d-%s", up.Date.Unix(), up.Name), rd)
+	if err != nil {
+		return "", errors.Wrapf(err, "can't store %s", up.Name)
+	}
+
+	item := feed.Item{
+		Title:       up.Title,
+		Author:      up.Author,
+		GUID:        fileURL,
+		PubDate:     up.Date.Format(time.RFC1123Z),
+		DT:          up.Date,
+		Description: template.HTML(up.Description), // nolint
+		Enclosure:   feed.Enclosure{URL: fileURL, Length: int(size), Type: up.MIME},
+	}
+	if up.Duration > 0 {
+		item.ITunesDuration = strconv.Itoa(up.Duration)
+	}
+	if err = p.saveItem(name, p.Conf.Feeds[name], publishSource, item); err != nil {
+		return "", err
+	}
+	log.Printf("[INFO] published %q (%s, %d bytes) to %s", item.Title, up.Name, size, name)
+	return name, nil
+}
+
+// publishFeed returns name of feed-set chosen for upload, the only feed-set if not chosen
+func (c *Conf) publishFeed(name string) (string, error) {
+	if name == "" {
+		if len(c.Feeds) != 1 {
+			return "", errors.New("feed-set not chosen, start caption with #name of it")
+		}
+		for n := range c.Feeds {
+			return n, nil
+		}
+	}
+	if _, ok := c.Feeds[name]; !ok {
+		return "", errors.Errorf("unknown feed-set %q", name)
+	}
+	return name, nil
+}
@@ -0,0 +1,97 @@
+package proc
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublish(t *testing.T) {
+	mediaDir, err := ioutil.TempDir("", "fm-media")
+	require.NoError(t, err)
+	defer os.RemoveAll(mediaDir)
+
+	tmpfile, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmpfile.Name())
+	boltDB, err := NewBoltDB(tmpfile.Name())
+	require.NoError(t, err)
+
+	conf := &Conf{Feeds: map[string]Feed{"podcast": {TelegramChannel: "chan", Tags: []TagRule{{Tag: "own", Source: "upload"}}}}}
+	conf.System.BaseURL = "https://fm.example.com"
+	notif := &telegramNotifMock{}
+	p := Processor{Conf: conf, Store: boltDB, TelegramNotif: notif, MediaDir: mediaDir}
+
+	dt := time.Date(2021, 7, 10, 15, 30, 0, 0, time.UTC)
+	up := Upload{Title: "Episode 12", Description: "<b>Episode 12</b><br>notes", Author: "John", Date: dt,
+		Name: "ep 12.mp3", MIME: "audio/mpeg", Duration: 300,
+		Open: func() (io.ReadCloser, error) { return ioutil.NopCloser(strings.NewReader("mp3 data")), nil }}
+	name, err := p.Publish(up)
+	require.NoError(t, err)
+	assert.Equal(t, "podcast", name, "the only feed-set")
+
+	items, err := boltDB.Load("podcast", 10, false)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(items))
+	item := items[0]
+	fileURL := "https://fm.example.com/files/podcast/1625931000-ep%2012.mp3"
+	assert.Equal(t, "Episode 12", item.Title)
+	assert.Equal(t, "John", item.Author)
+	assert.Equal(t, fileURL, item.GUID)
+	assert.Equal(t, dt, item.DT.UTC())
+	assert.Equal(t, "<b>Episode 12</b><br>notes", string(item.Description))
+	assert.Equal(t, fileURL, item.Enclosure.URL)
+	assert.Equal(t, 8, item.Enclosure.Length)
+	assert.Equal(t, "audio/mpeg", item.Enclosure.Type)
+	assert.Equal(t, "300", item.ITunesDuration)
+	assert.Equal(t, []string{"own"}, item.Tags)
+	data, err := ioutil.ReadFile(filepath.Join(mediaDir, "podcast", "1625931000-ep 12.mp3"))
+	require.NoError(t, err)
+	assert.Equal(t, "mp3 data", string(data))
+	require.Equal(t, 1, len(notif.sent))
+
+	up.Feed = "other"
+	_, err = p.Publish(up)
+	assert.EqualError(t, err, `unknown feed-set "other"`)
+
+	up.Feed, up.Name = "podcast", "broken.mp3"
+	up.Open = func() (io.ReadCloser, error) { return nil, errors.New("failed") }
+	_, err = p.Publish(up)
+	assert.EqualError(t, err, "can't download broken.mp3: failed")
+
+	up.Open = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(io.MultiReader(strings.NewReader("partial"), &errReader{})), nil
+	}
+	_, err = p.Publish(up)
+	assert.EqualError(t, err, "can't store broken.mp3: read failed")
+	_, err = os.Stat(filepath.Join(mediaDir, "podcast", "1625931000-broken.mp3"))
+	assert.True(t, os.IsNotExist(err), "partial file removed")
+
+	up.Open = func() (io.ReadCloser, error) { return ioutil.NopCloser(strings.NewReader("mp3 data")), nil }
+	require.NoError(t, boltDB.DB.Close())
+	_, err = p.Publish(up)
+	require.Error(t, err, "failed save reported")
+	assert.Contains(t, err.Error(), "failed to save")
+}
+
+func TestPublishFeed(t *testing.T) {
+	conf := Conf{Feeds: map[string]Feed{"f1": {}, "f2": {}}}
+	name, err := conf.publishFeed("f2")
+	require.NoError(t, err)
+	assert.Equal(t, "f2", name)
+	_, err = conf.publishFeed("")
+	assert.EqualError(t, err, "feed-set not chosen, start caption with #name of it")
+	_, err = conf.publishFeed("f3")
+	assert.EqualError(t, err, `unknown feed-set "f3"`)
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) { return 0, errors.New("read failed") }
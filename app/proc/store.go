@@ -1,12 +1,14 @@
 package proc
 
 import (
+	"bytes"
 	"crypto/sha1"
 	"encoding/json"
 	"fmt"
 	"time"
 
 	log "github.com/go-pkgz/lgr"
+	"github.com/pkg/errors"
 	bolt "go.etcd.io/bbolt"
 
 	"github.com/umputun/feed-master/app/feed"
@@ -17,55 +19,145 @@ type BoltDB struct {
 	DB *bolt.DB
 }
 
-// Save to bolt, skip if found
+// NewBoltDB makes persistent boltdb based store
+func NewBoltDB(dbFile string) (*BoltDB, error) {
+	log.Printf("[INFO] bolt (persistent) store, %s", dbFile)
+	db, err := bolt.Open(dbFile, 0600, &bolt.Options{Timeout: 1 * time.Second}) // nolint
+	if err != nil {
+		return nil, err
+	}
+	return &BoltDB{DB: db}, nil
+}
+
+// indexBucket is a nested bucket in each feed-set bucket, maps item's identity hash to item's key
+var indexBucket = []byte("_index")
+
+// Save to bolt, returns true if item is new. Items are identified by guid, enclosure url, link or title
+// with description (see feed.Item.ID), regardless of pubDate, item without any of them rejected.
+// Known item with changed content updated in place, keeping its position.
+// Item without a date gets the time it was first seen, known item without its own date keeps the stored one.
 func (b BoltDB) Save(fmFeed string, item feed.Item) (bool, error) {
 	var created bool
 
-	key, err := func() ([]byte, error) {
-		ts, err := time.Parse(time.RFC1123Z, item.PubDate)
-		if err != nil {
-			return nil, err
-		}
-		h := sha1.New()
-		if _, err = h.Write([]byte(item.GUID)); err != nil {
-			return nil, err
-		}
-		return []byte(fmt.Sprintf("%d-%x", ts.Unix(), h.Sum(nil))), nil
-	}()
-
+	if item.ID() == "" {
+		return created, errors.New("item has no guid, enclosure, link, title or description")
+	}
+	idKey, err := hashKey(item.ID())
 	if err != nil {
 		return created, err
 	}
 
+	if item.DT.IsZero() {
+		if dt, e := time.Parse(time.RFC1123Z, item.PubDate); e == nil {
+			item.DT = dt
+		}
+	}
+
 	err = b.DB.Update(func(tx *bolt.Tx) error {
 		bucket, e := tx.CreateBucketIfNotExists([]byte(fmFeed))
 		if e != nil {
 			return e
 		}
-		if bucket.Get(key) != nil {
-			return nil
+		index, e := b.index(bucket)
+		if e != nil {
+			return e
 		}
 
+		var old []byte
+		key := index.Get(idKey)
+		if key != nil {
+			if old = bucket.Get(key); old == nil {
+				log.Printf("[WARN] index of %s points to missing item %s, save as new", item.ID(), string(key))
+			}
+		}
+		if old != nil {
+			if item.DT.IsZero() || item.DateFallback { // keep stored date of item without its own date
+				oldItem := feed.Item{}
+				if e = json.Unmarshal(old, &oldItem); e != nil {
+					return e
+				}
+				item.DT, item.PubDate = oldItem.DT, oldItem.PubDate
+			}
+			jdata, jerr := json.Marshal(&item)
+			if jerr != nil {
+				return jerr
+			}
+			if bytes.Equal(old, jdata) {
+				return nil
+			}
+			log.Printf("[INFO] update %s - %s - %s - %s", string(key), fmFeed, item.Title, item.GUID)
+			return bucket.Put(key, jdata)
+		}
+
+		if item.DT.IsZero() {
+			// no usable date, first seen time stored with the item keeps its date and position stable
+			log.Printf("[DEBUG] can't parse pubDate %q of %s, use first seen time", item.PubDate, item.ID())
+			item.DT = time.Now()
+			item.PubDate = item.DT.Format(time.RFC1123Z)
+		}
 		jdata, jerr := json.Marshal(&item)
 		if jerr != nil {
 			return jerr
 		}
+		key = []byte(fmt.Sprintf("%d-%x", item.DT.Unix(), idKey))
 
 		log.Printf("[INFO] save %s - %s - %s - %s", string(key), fmFeed, item.Title, item.GUID)
-		e = bucket.Put(key, jdata)
-		if e != nil {
+		if e = bucket.Put(key, jdata); e != nil {
+			return e
+		}
+		if e = index.Put(idKey, key); e != nil {
 			return e
 		}
-
 		created = true
-		return e
+		return nil
 	})
 
 	return created, err
 }
 
+// index returns identity index of feed-set bucket, makes and populates it from stored items if missing
+func (b BoltDB) index(bucket *bolt.Bucket) (*bolt.Bucket, error) {
+	if index := bucket.Bucket(indexBucket); index != nil {
+		return index, nil
+	}
+
+	index, err := bucket.CreateBucket(indexBucket)
+	if err != nil {
+		return nil, err
+	}
+	err = bucket.ForEach(func(k, v []byte) error {
+		if v == nil { // nested bucket
+			return nil
+		}
+		item := feed.Item{}
+		if e := json.Unmarshal(v, &item); e != nil {
+			log.Printf("[WARN] failed to unmarshal, %v", e)
+			return nil
+		}
+		idKey, e := hashKey(item.ID())
+		if e != nil {
+			return e
+		}
+		return index.Put(idKey, k)
+	})
+	return index, err
+}
+
+func hashKey(id string) ([]byte, error) {
+	h := sha1.New()
+	if _, err := h.Write([]byte(id)); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
 // Load from bold for given feed, up to max
 func (b BoltDB) Load(fmFeed string, max int, skipJunk bool) ([]feed.Item, error) {
+	return b.LoadTagged(fmFeed, max, skipJunk, "")
+}
+
+// LoadTagged returns up to max items with the tag from the bucket, all items for empty tag
+func (b BoltDB) LoadTagged(fmFeed string, max int, skipJunk bool, tag string) ([]feed.Item, error) {
 	var result []feed.Item
 
 	err := b.DB.View(func(tx *bolt.Tx) error {
@@ -75,6 +167,9 @@ func (b BoltDB) Load(fmFeed string, max int, skipJunk bool) ([]feed.Item, error)
 		}
 		c := bucket.Cursor()
 		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			if v == nil { // nested index bucket
+				continue
+			}
 			item := feed.Item{}
 			if err := json.Unmarshal(v, &item); err != nil {
 				log.Printf("[WARN] failed to unmarshal, %v", err)
@@ -83,6 +178,9 @@ func (b BoltDB) Load(fmFeed string, max int, skipJunk bool) ([]feed.Item, error)
 			if skipJunk && item.Junk {
 				continue
 			}
+			if tag != "" && !item.HasTag(tag) {
+				continue
+			}
 			if len(result) >= max {
 				break
 			}
@@ -100,17 +198,43 @@ func (b BoltDB) removeOld(fmFeed string, keep int) (int, error) {
 		if bucket == nil {
 			return fmt.Errorf("no bucket for %s", fmFeed)
 		}
+		index, err := b.index(bucket)
+		if err != nil {
+			return err
+		}
+
 		recs := 0
+		var oldKeys [][]byte
 		c := bucket.Cursor()
-		var err error
-		for k, _ := c.Last(); k != nil; k, _ = c.Prev() {
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			if v == nil { // nested index bucket
+				continue
+			}
 			recs++
-			if recs > keep {
-				if e := bucket.Delete(k); e != nil {
-					err = e
-				}
-				deleted++
+			if recs <= keep {
+				continue
+			}
+			oldKeys = append(oldKeys, k)
+			item := feed.Item{}
+			if e := json.Unmarshal(v, &item); e != nil {
+				log.Printf("[WARN] failed to unmarshal, %v", e)
+				continue
+			}
+			idKey, e := hashKey(item.ID())
+			if e != nil {
+				return e
+			}
+			if e := index.Delete(idKey); e != nil {
+				err = e
+			}
+		}
+
+		for _, k := range oldKeys {
+			if e := bucket.Delete(k); e != nil {
+				err = e
+				continue
 			}
+			deleted++
 		}
 		return err
 	})
@@ -1,15 +1,18 @@
 package proc
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"os"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/umputun/feed-master/app/feed"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
 )
 
 const pubDate = "Mon, 02 Jan 2006 15:04:05 -0700"
@@ -38,13 +41,17 @@ func TestSaveIfInvalidPubDate(t *testing.T) {
 	boltDB, _ := NewBoltDB(tmpfile.Name())
 
 	item := feed.Item{
+		GUID:    "1",
 		PubDate: "100500",
 	}
 
 	created, err := boltDB.Save("radio-t", item)
+	assert.True(t, created, "saved with first seen time")
+	assert.NoError(t, err)
 
-	assert.False(t, created)
-	assert.EqualError(t, err, "parsing time \"100500\" as \"Mon, 02 Jan 2006 15:04:05 -0700\": cannot parse \"100500\" as \"Mon\"")
+	created, err = boltDB.Save("radio-t", item)
+	assert.False(t, created, "found by identity")
+	assert.NoError(t, err)
 }
 
 func TestSave(t *testing.T) {
@@ -54,6 +61,7 @@ func TestSave(t *testing.T) {
 	boltDB, _ := NewBoltDB(tmpfile.Name())
 
 	item := feed.Item{
+		GUID:    "1",
 		PubDate: pubDate,
 	}
 
@@ -70,6 +78,7 @@ func TestSaveIfItemIsExists(t *testing.T) {
 	boltDB, _ := NewBoltDB(tmpfile.Name())
 
 	item := feed.Item{
+		GUID:    "1",
 		PubDate: pubDate,
 	}
 	_, err := boltDB.Save("radio-t", item)
@@ -97,7 +106,7 @@ func TestLoad(t *testing.T) {
 	defer os.Remove(tmpfile.Name())
 
 	boltDB, _ := NewBoltDB(tmpfile.Name())
-	_, err := boltDB.Save("radio-t", feed.Item{PubDate: pubDate})
+	_, err := boltDB.Save("radio-t", feed.Item{PubDate: pubDate, GUID: "1"})
 	require.NoError(t, err)
 
 	items, err := boltDB.Load("radio-t", 5, false)
@@ -151,7 +160,7 @@ func TestBuckets(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 0, len(got))
 
-	_, err = boltDB.Save("radio-t", feed.Item{PubDate: pubDate})
+	_, err = boltDB.Save("radio-t", feed.Item{PubDate: pubDate, GUID: "1"})
 	require.NoError(t, err)
 
 	got, err = boltDB.Buckets()
@@ -200,3 +209,258 @@ func TestRemoveOld(t *testing.T) {
 		})
 	}
 }
+
+func TestSaveOutOfOrder(t *testing.T) {
+	tmpfile, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmpfile.Name())
+	boltDB, _ := NewBoltDB(tmpfile.Name())
+
+	created, err := boltDB.Save("radio-t", feed.Item{PubDate: "Mon, 02 Jan 2006 15:04:05 -0700", GUID: "2"})
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	// back-dated item published after the newer one
+	created, err = boltDB.Save("radio-t", feed.Item{PubDate: "Mon, 02 Jan 2006 10:04:05 -0700", GUID: "1"})
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	items, err := boltDB.Load("radio-t", 5, false)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(items))
+	assert.Equal(t, "2", items[0].GUID, "sorted by date")
+	assert.Equal(t, "1", items[1].GUID)
+}
+
+func TestSaveUpdateInPlace(t *testing.T) {
+	tmpfile, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmpfile.Name())
+	boltDB, _ := NewBoltDB(tmpfile.Name())
+
+	_, err := boltDB.Save("radio-t", feed.Item{PubDate: "Mon, 02 Jan 2006 15:04:05 -0700", GUID: "1", Title: "t1"})
+	require.NoError(t, err)
+	_, err = boltDB.Save("radio-t", feed.Item{PubDate: "Mon, 02 Jan 2006 16:04:05 -0700", GUID: "2", Title: "t2"})
+	require.NoError(t, err)
+
+	// same guid with edited pubDate and title
+	created, err := boltDB.Save("radio-t", feed.Item{PubDate: "Tue, 03 Jan 2006 15:04:05 -0700", GUID: "1", Title: "t1 edited"})
+	require.NoError(t, err)
+	assert.False(t, created)
+
+	items, err := boltDB.Load("radio-t", 5, false)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(items), "no duplicates")
+	assert.Equal(t, "t2", items[0].Title)
+	assert.Equal(t, "t1 edited", items[1].Title, "updated, position kept")
+	assert.Equal(t, "Tue, 03 Jan 2006 15:04:05 -0700", items[1].PubDate)
+}
+
+func TestSaveIdentityFallback(t *testing.T) {
+	tmpfile, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmpfile.Name())
+	boltDB, _ := NewBoltDB(tmpfile.Name())
+
+	created, err := boltDB.Save("radio-t", feed.Item{PubDate: pubDate, Enclosure: feed.Enclosure{URL: "http://example.com/1.mp3"}})
+	require.NoError(t, err)
+	assert.True(t, created)
+	created, err = boltDB.Save("radio-t", feed.Item{PubDate: pubDate, Link: "http://example.com/1"})
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	created, err = boltDB.Save("radio-t", feed.Item{PubDate: "Tue, 03 Jan 2006 15:04:05 -0700",
+		Enclosure: feed.Enclosure{URL: "http://example.com/1.mp3"}})
+	require.NoError(t, err)
+	assert.False(t, created)
+	created, err = boltDB.Save("radio-t", feed.Item{PubDate: pubDate, Link: "http://example.com/1", Title: "new title"})
+	require.NoError(t, err)
+	assert.False(t, created)
+}
+
+func TestSaveKeepsFallbackDate(t *testing.T) {
+	tmpfile, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmpfile.Name())
+	boltDB, _ := NewBoltDB(tmpfile.Name())
+
+	first := time.Date(2021, 7, 10, 15, 30, 0, 0, time.UTC)
+	created, err := boltDB.Save("radio-t", feed.Item{GUID: "1", Title: "t1", DT: first,
+		PubDate: first.Format(time.RFC1123Z), DateFallback: true})
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	// channel's date changed on regeneration of the feed
+	later := first.Add(time.Hour)
+	created, err = boltDB.Save("radio-t", feed.Item{GUID: "1", Title: "t2", DT: later,
+		PubDate: later.Format(time.RFC1123Z), DateFallback: true})
+	require.NoError(t, err)
+	assert.False(t, created)
+
+	items, err := boltDB.Load("radio-t", 10, false)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(items))
+	assert.Equal(t, "t2", items[0].Title, "updated in place")
+	assert.Equal(t, first, items[0].DT.UTC(), "stored date kept")
+	assert.Equal(t, first.Format(time.RFC1123Z), items[0].PubDate)
+
+	// own date of item replaces the stored one
+	created, err = boltDB.Save("radio-t", feed.Item{GUID: "1", Title: "t2", DT: later, PubDate: later.Format(time.RFC1123Z)})
+	require.NoError(t, err)
+	assert.False(t, created)
+	items, err = boltDB.Load("radio-t", 10, false)
+	require.NoError(t, err)
+	assert.Equal(t, later, items[0].DT.UTC())
+}
+
+func TestSaveWithoutIdentity(t *testing.T) {
+	tmpfile, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmpfile.Name())
+	boltDB, _ := NewBoltDB(tmpfile.Name())
+
+	_, err := boltDB.Save("radio-t", feed.Item{PubDate: pubDate})
+	assert.EqualError(t, err, "item has no guid, enclosure, link, title or description")
+
+	// scraped items without guid and links identified by title and description
+	created, err := boltDB.Save("radio-t", feed.Item{PubDate: pubDate, Title: "title 1", Description: "descr"})
+	require.NoError(t, err)
+	assert.True(t, created)
+	created, err = boltDB.Save("radio-t", feed.Item{PubDate: pubDate, Title: "title 2", Description: "descr"})
+	require.NoError(t, err)
+	assert.True(t, created, "different item not overwriting the first one")
+	created, err = boltDB.Save("radio-t", feed.Item{PubDate: pubDate, Title: "title 1", Description: "descr"})
+	require.NoError(t, err)
+	assert.False(t, created)
+
+	items, err := boltDB.Load("radio-t", 10, false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(items))
+}
+
+func TestSaveLegacyBucketWithoutIndex(t *testing.T) {
+	tmpfile, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmpfile.Name())
+	boltDB, _ := NewBoltDB(tmpfile.Name())
+
+	// item stored before identity index was introduced
+	err := boltDB.DB.Update(func(tx *bolt.Tx) error {
+		bucket, e := tx.CreateBucketIfNotExists([]byte("radio-t"))
+		require.NoError(t, e)
+		data, e := json.Marshal(feed.Item{PubDate: pubDate, GUID: "1"})
+		require.NoError(t, e)
+		return bucket.Put([]byte("1136239445-356a192b7913b04c54574d18c28d46e6395428ab"), data)
+	})
+	require.NoError(t, err)
+
+	created, err := boltDB.Save("radio-t", feed.Item{PubDate: pubDate, GUID: "1"})
+	require.NoError(t, err)
+	assert.False(t, created, "found in index built from stored items")
+}
+
+func TestRemoveOldCleansIndex(t *testing.T) {
+	tmpfile, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmpfile.Name())
+	boltDB, _ := NewBoltDB(tmpfile.Name())
+
+	_, err := boltDB.Save("radio-t", feed.Item{PubDate: "Mon, 02 Jan 2006 15:04:05 -0700", GUID: "1"})
+	require.NoError(t, err)
+	_, err = boltDB.Save("radio-t", feed.Item{PubDate: "Mon, 02 Jan 2006 16:04:05 -0700", GUID: "2"})
+	require.NoError(t, err)
+
+	count, err := boltDB.removeOld("radio-t", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	err = boltDB.DB.View(func(tx *bolt.Tx) error {
+		assert.Equal(t, 1, tx.Bucket([]byte("radio-t")).Bucket(indexBucket).Stats().KeyN)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestSaveFirstSeenTime(t *testing.T) {
+	tmpfile, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmpfile.Name())
+	boltDB, _ := NewBoltDB(tmpfile.Name())
+
+	created, err := boltDB.Save("radio-t", feed.Item{GUID: "1", Title: "t1"})
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	items, err := boltDB.Load("radio-t", 5, false)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(items))
+	firstSeen := items[0].DT
+	assert.False(t, firstSeen.IsZero())
+	assert.Equal(t, firstSeen.Format(time.RFC1123Z), items[0].PubDate)
+
+	time.Sleep(time.Second)
+	created, err = boltDB.Save("radio-t", feed.Item{GUID: "1", Title: "t1 edited"})
+	require.NoError(t, err)
+	assert.False(t, created)
+
+	items, err = boltDB.Load("radio-t", 5, false)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(items))
+	assert.Equal(t, "t1 edited", items[0].Title)
+	assert.True(t, firstSeen.Equal(items[0].DT), "first seen time kept")
+	assert.Equal(t, firstSeen.Format(time.RFC1123Z), items[0].PubDate)
+}
+
+func TestSavePodcastExtensions(t *testing.T) {
+	tmpfile, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmpfile.Name())
+	boltDB, _ := NewBoltDB(tmpfile.Name())
+
+	item := feed.Item{PubDate: pubDate, GUID: "1", Title: "t1",
+		ITunesDuration:     "3600",
+		ITunesImage:        &feed.ITunesImage{Href: "https://example.com/1.png"},
+		PodcastTranscripts: []feed.PodcastTranscript{{URL: "https://example.com/1.vtt", Type: "text/vtt"}},
+		PodcastChapters:    &feed.PodcastChapters{URL: "https://example.com/1.json", Type: "application/json+chapters"},
+		PodcastPersons:     []feed.PodcastPerson{{Name: "Host", Role: "host"}},
+		PodcastFunding:     []feed.PodcastFunding{{URL: "https://example.com/donate", Text: "Support"}},
+		PodcastFeedGUID:    "917393e3-1b1e-5cef-ace4-edaa54e1f810",
+	}
+	_, err := boltDB.Save("radio-t", item)
+	require.NoError(t, err)
+
+	items, err := boltDB.Load("radio-t", 5, false)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(items))
+	assert.Equal(t, item.ITunesImage, items[0].ITunesImage)
+	assert.Equal(t, item.PodcastTranscripts, items[0].PodcastTranscripts)
+	assert.Equal(t, item.PodcastChapters, items[0].PodcastChapters)
+	assert.Equal(t, item.PodcastPersons, items[0].PodcastPersons)
+	assert.Equal(t, item.PodcastFunding, items[0].PodcastFunding)
+	assert.Equal(t, item.PodcastFeedGUID, items[0].PodcastFeedGUID)
+}
+
+func TestSaveWithStaleIndex(t *testing.T) {
+	tmpfile, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmpfile.Name())
+	boltDB, _ := NewBoltDB(tmpfile.Name())
+
+	item := feed.Item{GUID: "1", Title: "title", PubDate: pubDate}
+	created, err := boltDB.Save("radio-t", item)
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	// item removed, its index entry left behind
+	err = boltDB.DB.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("radio-t"))
+		k, _ := bucket.Cursor().First()
+		return bucket.Delete(k)
+	})
+	require.NoError(t, err)
+	items, err := boltDB.Load("radio-t", 10, false)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(items))
+
+	created, err = boltDB.Save("radio-t", item)
+	require.NoError(t, err)
+	assert.True(t, created, "saved as new")
+	items, err = boltDB.Load("radio-t", 10, false)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(items))
+	assert.Equal(t, "title", items[0].Title)
+
+	created, err = boltDB.Save("radio-t", item)
+	require.NoError(t, err)
+	assert.False(t, created, "index rewritten")
+}
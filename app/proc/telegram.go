@@ -0,0 +1,195 @@
+package proc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/pkg/errors"
+	"github.com/tcolgate/mp3"
+	"golang.org/x/net/html"
+	tb "gopkg.in/tucnak/telebot.v2"
+
+	"github.com/umputun/feed-master/app/feed"
+)
+
+// TelegramClient sends feed items to telegram channels
+type TelegramClient struct {
+	Bot     *tb.Bot
+	Timeout time.Duration
+	Fetcher *feed.Fetcher // used for audio downloads and feeds discovery, default fetcher if nil
+}
+
+// NewTelegramClient init telegram client, makes no-op client for empty token
+func NewTelegramClient(token, apiURL string, timeout time.Duration) (*TelegramClient, error) {
+	if timeout == 0 {
+		timeout = time.Second * 60
+	}
+
+	if token == "" {
+		return &TelegramClient{Timeout: timeout}, nil
+	}
+
+	bot, err := tb.NewBot(tb.Settings{
+		URL:    apiURL,
+		Token:  token,
+		Client: &http.Client{Timeout: timeout},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TelegramClient{Bot: bot, Timeout: timeout}, nil
+}
+
+// Send item's audio to channel, falls back to text message for too large files. Skipped if bot or channel not set
+func (client TelegramClient) Send(channelID string, item feed.Item) (err error) {
+	if client.Bot == nil || channelID == "" {
+		return nil
+	}
+
+	var message *tb.Message
+	if item.Enclosure.URL == "" || !strings.HasPrefix(item.Enclosure.Type, "audio/") {
+		// nothing to upload as audio, send as text message with link
+		message, err = client.sendText(channelID, item)
+	} else {
+		message, err = client.sendAudio(channelID, item)
+		if err != nil && strings.Contains(err.Error(), "Request Entity Too Large") {
+			message, err = client.sendText(channelID, item)
+		}
+	}
+
+	if err != nil {
+		return errors.Wrapf(err, "can't send to telegram for %+v", item.Enclosure)
+	}
+
+	log.Printf("[DEBUG] telegram message sent: \n%s", message.Text)
+	return nil
+}
+
+func (client TelegramClient) sendText(channelID string, item feed.Item) (*tb.Message, error) {
+	message, err := client.Bot.Send(
+		recipient{chatID: channelID},
+		client.getMessageHTML(item, true),
+		tb.ModeHTML,
+		tb.NoPreview,
+	)
+
+	return message, err
+}
+
+func (client TelegramClient) sendAudio(channelID string, item feed.Item) (*tb.Message, error) {
+	httpBody, err := item.DownloadAudio(context.Background(), client.Fetcher, client.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer httpBody.Close() // nolint
+
+	// download audio to the temp file, it is used to get duration and to upload
+	tmpFile, err := ioutil.TempFile(os.TempDir(), "feed-master-*.mp3")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tmpFile.Close()
+		if e := os.Remove(tmpFile.Name()); e != nil {
+			log.Printf("[WARN] failed to remove %s, %v", tmpFile.Name(), e)
+		}
+	}()
+
+	if _, err = io.Copy(tmpFile, httpBody); err != nil {
+		return nil, err
+	}
+	if _, err = tmpFile.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	audio := tb.Audio{
+		File:     tb.FromDisk(tmpFile.Name()),
+		FileName: item.GetFilename(),
+		MIME:     "audio/mpeg",
+		Caption:  client.getMessageHTML(item, false),
+		Title:    item.Title,
+		Duration: client.duration(tmpFile),
+	}
+
+	return audio.Send(client.Bot, recipient{chatID: channelID}, &tb.SendOptions{ParseMode: tb.ModeHTML})
+}
+
+// duration scans MP3 file from provided io.Reader and returns it's duration in seconds, ignoring possible errors
+func (client TelegramClient) duration(r io.Reader) int {
+	d := mp3.NewDecoder(r)
+	var f mp3.Frame
+	var skipped int
+	var duration float64
+	var err error
+
+	for err == nil {
+		if err = d.Decode(&f, &skipped); err != nil && err != io.EOF {
+			log.Printf("[WARN] can't get duration for provided stream, %v", err)
+			return 0
+		}
+		duration += f.Duration().Seconds()
+	}
+	return int(duration)
+}
+
+// https://core.telegram.org/bots/api#html-style
+func (client TelegramClient) tagLinkOnlySupport(htmlText string) string {
+	p := bluemonday.NewPolicy()
+	p.AllowAttrs("href").OnElements("a")
+	return html.UnescapeString(p.Sanitize(htmlText))
+}
+
+// getMessageHTML generates HTML message from provided feed.Item
+func (client TelegramClient) getMessageHTML(item feed.Item, withMp3Link bool) string {
+	description := string(item.Description)
+
+	description = strings.TrimPrefix(description, "<![CDATA[")
+	description = strings.TrimSuffix(description, "]]>")
+
+	// apparently bluemonday doesn't remove escaped HTML tags
+	description = client.tagLinkOnlySupport(html.UnescapeString(description))
+	description = strings.TrimSpace(description)
+
+	messageHTML := description
+
+	title := strings.TrimSpace(item.Title)
+	if title != "" {
+		switch {
+		case item.Link == "":
+			messageHTML = fmt.Sprintf("%s\n\n", title) + messageHTML
+		case item.Link != "":
+			messageHTML = fmt.Sprintf("<a href=\"%s\">%s</a>\n\n", item.Link, title) + messageHTML
+		}
+	}
+
+	if withMp3Link {
+		messageHTML += fmt.Sprintf("\n\n%s", item.Enclosure.URL)
+	}
+
+	return messageHTML
+}
+
+type recipient struct {
+	chatID string
+}
+
+// Recipient returns chat id for numeric ids and @-prefixed channel name otherwise
+func (r recipient) Recipient() string {
+	if _, err := strconv.ParseInt(r.chatID, 10, 64); err == nil {
+		return r.chatID
+	}
+	if !strings.HasPrefix(r.chatID, "@") {
+		return "@" + r.chatID
+	}
+	return r.chatID
+}
@@ -2,11 +2,18 @@ package proc
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"strconv"
 	"testing"
 	"testing/iotest"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	tb "gopkg.in/tucnak/telebot.v2"
@@ -56,6 +63,26 @@ func TestSendIfChannelIDEmpty(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestSendAsText(t *testing.T) {
+	var sent []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = append(sent, r.URL.Path)
+		_, _ = w.Write([]byte(`{"ok":true,"result":{"message_id":1,"chat":{"id":1}}}`))
+	}))
+	defer ts.Close()
+
+	bot, err := tb.NewBot(tb.Settings{URL: ts.URL, Token: "token", Offline: true})
+	require.NoError(t, err)
+	client := TelegramClient{Bot: bot, Timeout: time.Second}
+
+	err = client.Send("@channel", feed.Item{Title: "no enclosure", Link: "http://example.com/1"})
+	require.NoError(t, err)
+	err = client.Send("@channel", feed.Item{Title: "video", Link: "http://example.com/2",
+		Enclosure: feed.Enclosure{URL: "http://example.com/2.mp4", Type: "video/mp4"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/bottoken/sendMessage", "/bottoken/sendMessage"}, sent)
+}
+
 func TestTagLinkOnlySupport(t *testing.T) {
 	html := `
 <li>Особое канадское искусство. </li>
@@ -177,3 +204,198 @@ func TestDurationGoodContent(t *testing.T) {
 	duration := client.duration(reader)
 	assert.Zero(t, duration)
 }
+
+func TestEntitiesHTML(t *testing.T) {
+	tbl := []struct {
+		text     string
+		entities []tb.MessageEntity
+		res      string
+	}{
+		{"plain <text>\nline 2", nil, "plain &lt;text&gt;<br>line 2"},
+		{"bold and link", []tb.MessageEntity{{Type: tb.EntityBold, Offset: 0, Length: 4},
+			{Type: tb.EntityTextLink, Offset: 9, Length: 4, URL: "https://example.com/?a=1&b=2"}},
+			`<b>bold</b> and <a href="https://example.com/?a=1&amp;b=2">link</a>`},
+		{"😀 example.com #tag @chan", []tb.MessageEntity{{Type: tb.EntityURL, Offset: 3, Length: 11},
+			{Type: tb.EntityHashtag, Offset: 15, Length: 4}, {Type: tb.EntityMention, Offset: 20, Length: 5}},
+			`😀 <a href="http://example.com">example.com</a> #tag <a href="https://t.me/chan">@chan</a>`},
+		{"nested text", []tb.MessageEntity{{Type: tb.EntityItalic, Offset: 0, Length: 11},
+			{Type: tb.EntityBold, Offset: 0, Length: 6}, {Type: tb.EntityUnderline, Offset: 7, Length: 10}},
+			"<i><b>nested</b> <u>text</u></i>"},
+		{"crossed", []tb.MessageEntity{{Type: tb.EntityBold, Offset: 0, Length: 4},
+			{Type: tb.EntityItalic, Offset: 2, Length: 5}}, "<b>cr<i>os</i></b>sed"},
+	}
+	for i, tt := range tbl {
+		assert.Equal(t, tt.res, entitiesHTML(tt.text, tt.entities), "case #%d", i)
+	}
+}
+
+func TestChannelPost(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bottoken/getFile":
+			var req struct {
+				FileID string `json:"file_id"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			_, _ = fmt.Fprintf(w, `{"ok":true,"result":{"file_id":%q,"file_path":"files/%s"}}`, req.FileID, req.FileID)
+		case "/file/bottoken/files/photo1":
+			_, _ = w.Write([]byte("jpeg"))
+		case "/file/bottoken/files/audio1":
+			_, _ = w.Write([]byte("mp3"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	bot, err := tb.NewBot(tb.Settings{URL: ts.URL, Token: "token", Offline: true})
+	require.NoError(t, err)
+	var delivered []ChannelPost
+	client := TelegramClientV2{TelegramClient: TelegramClient{Bot: bot},
+		AcceptPost:  func(chat *tb.Chat) bool { return chat.Username == "news" },
+		DeliverPost: func(post ChannelPost) error { delivered = append(delivered, post); return nil },
+	}
+
+	chat := &tb.Chat{ID: -100123, Type: tb.ChatChannel, Title: "News", Username: "news"}
+	client.channelPost(&tb.Message{ID: 42, Chat: chat, Unixtime: 1626000000, Signature: "John",
+		Caption: "Episode 12", CaptionEntities: []tb.MessageEntity{{Type: tb.EntityBold, Length: 7}},
+		Photo: &tb.Photo{File: tb.File{FileID: "photo1"}},
+		Audio: &tb.Audio{File: tb.File{FileID: "audio1"}, Title: "Ep 12", Performer: "Show", Duration: 300, FileName: "../ep12.mp3"},
+	})
+	client.channelPost(&tb.Message{ID: 1, Chat: &tb.Chat{ID: -100456, Type: tb.ChatChannel, Title: "Other"}, Text: "ignored"})
+	client.channelPost(&tb.Message{ID: 43, Chat: chat, Voice: &tb.Voice{File: tb.File{FileID: "missing"}}})
+	client.channelPost(&tb.Message{ID: 44, Chat: chat, Voice: &tb.Voice{File: tb.File{FileID: "big", FileSize: 30 * 1024 * 1024}}})
+
+	require.Len(t, delivered, 1, "other channel and failed downloads skipped")
+	post := delivered[0]
+	assert.Equal(t, 42, post.ID)
+	assert.Equal(t, chat, post.Chat)
+	assert.Equal(t, time.Unix(1626000000, 0), post.Date)
+	assert.Equal(t, "John", post.Author)
+	assert.Equal(t, "Episode 12", post.Text)
+	assert.Equal(t, "<b>Episode</b> 12", post.HTML)
+	assert.Equal(t, &PostFile{Name: "42-photo.jpg", MIME: "image/jpeg", Data: []byte("jpeg")}, post.Photo)
+	assert.Equal(t, &PostFile{Name: "42-ep12.mp3", MIME: "audio/mpeg", Title: "Show - Ep 12", Duration: 300,
+		Data: []byte("mp3")}, post.Audio)
+}
+
+func TestUploadCaption(t *testing.T) {
+	tbl := []struct {
+		caption            string
+		entities           []tb.MessageEntity
+		name, title, descr string
+	}{
+		{"", nil, "", "audio title", ""},
+		{"#podcast", nil, "podcast", "audio title", ""},
+		{"Episode 12\nnotes", []tb.MessageEntity{{Type: tb.EntityBold, Offset: 0, Length: 10}},
+			"", "Episode 12", "<b>Episode 12</b><br>notes"},
+		{"#podcast  Эпизод 12\nnotes #tag", []tb.MessageEntity{{Type: tb.EntityHashtag, Offset: 0, Length: 8},
+			{Type: tb.EntityItalic, Offset: 10, Length: 6}}, "podcast", "Эпизод 12", "<i>Эпизод</i> 12<br>notes #tag"},
+		{"#p\n\nTitle", nil, "p", "Title", "Title"},
+	}
+	for i, tt := range tbl {
+		name, title, descr := uploadCaption(tt.caption, tt.entities, "audio title")
+		assert.Equal(t, tt.name, name, "case #%d", i)
+		assert.Equal(t, tt.title, title, "case #%d", i)
+		assert.Equal(t, tt.descr, descr, "case #%d", i)
+	}
+}
+
+func TestUpload(t *testing.T) {
+	localFile, err := ioutil.TempFile("", "fm-upload")
+	require.NoError(t, err)
+	defer os.Remove(localFile.Name())
+	_, err = localFile.WriteString("local mp3")
+	require.NoError(t, err)
+	require.NoError(t, localFile.Close())
+
+	var sent []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		switch r.URL.Path {
+		case "/bottoken/sendMessage":
+			sent = append(sent, req["text"])
+			_, _ = w.Write([]byte(`{"ok":true,"result":{"message_id":1,"chat":{"id":1}}}`))
+		case "/bottoken/getFile":
+			filePath := "files/" + req["file_id"]
+			if req["file_id"] == "local" {
+				filePath = localFile.Name()
+			}
+			_, _ = fmt.Fprintf(w, `{"ok":true,"result":{"file_id":%q,"file_path":%q}}`, req["file_id"], filePath)
+		case "/file/bottoken/files/audio1":
+			assert.Equal(t, "test-agent", r.Header.Get("User-Agent"), "got by client's fetcher")
+			_, _ = w.Write([]byte("mp3"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	bot, err := tb.NewBot(tb.Settings{URL: ts.URL, Token: "token", Offline: true})
+	require.NoError(t, err)
+	fetcher, err := feed.NewFetcher(feed.FetchOpts{UserAgent: "test-agent"})
+	require.NoError(t, err)
+	var uploads []Upload
+	var data []string
+	client := TelegramClientV2{TelegramClient: TelegramClient{Bot: bot, Timeout: time.Second, Fetcher: fetcher},
+		Admins: []string{"@Admin", "42"},
+		Publish: func(up Upload) (string, error) {
+			if up.Feed == "broken" {
+				return "", errors.New("unknown feed-set \"broken\"")
+			}
+			rd, err := up.Open()
+			require.NoError(t, err)
+			defer rd.Close()
+			b, err := ioutil.ReadAll(rd)
+			require.NoError(t, err)
+			uploads, data = append(uploads, up), append(data, string(b))
+			return "podcast", nil
+		},
+	}
+
+	admin := &tb.User{ID: 1, Username: "admin", FirstName: "John", LastName: "Doe"}
+	private := &tb.Chat{ID: 1, Type: tb.ChatPrivate}
+	client.upload(&tb.Message{Chat: private, Sender: admin, Unixtime: 1626000000, Caption: "#podcast Episode 12\nnotes",
+		Audio: &tb.Audio{File: tb.File{FileID: "audio1"}, Duration: 300, FileName: "ep12.mp3", MIME: "audio/mpeg"}})
+	client.upload(&tb.Message{Chat: private, Sender: &tb.User{ID: 42}, Unixtime: 1626000000,
+		Document: &tb.Document{File: tb.File{FileID: "local"}, FileName: "../ep13.m4a"}})
+	client.upload(&tb.Message{Chat: private, Sender: admin, Caption: "#broken",
+		Audio: &tb.Audio{File: tb.File{FileID: "audio1"}}})
+	client.upload(&tb.Message{Chat: private, Sender: &tb.User{ID: 2, Username: "other"},
+		Audio: &tb.Audio{File: tb.File{FileID: "audio1"}}})
+	client.upload(&tb.Message{Chat: &tb.Chat{ID: -1, Type: tb.ChatGroup}, Sender: admin,
+		Audio: &tb.Audio{File: tb.File{FileID: "audio1"}}})
+
+	require.Len(t, uploads, 2)
+	assert.Equal(t, "podcast", uploads[0].Feed)
+	assert.Equal(t, "Episode 12", uploads[0].Title)
+	assert.Equal(t, "Episode 12<br>notes", uploads[0].Description)
+	assert.Equal(t, "John Doe", uploads[0].Author)
+	assert.Equal(t, time.Unix(1626000000, 0), uploads[0].Date)
+	assert.Equal(t, "ep12.mp3", uploads[0].Name)
+	assert.Equal(t, "audio/mpeg", uploads[0].MIME)
+	assert.Equal(t, 300, uploads[0].Duration)
+	assert.Equal(t, "mp3", data[0])
+
+	assert.Equal(t, "", uploads[1].Feed)
+	assert.Equal(t, "ep13", uploads[1].Title, "file name used as title")
+	assert.Equal(t, "ep13.m4a", uploads[1].Name)
+	assert.Equal(t, "audio/mpeg", uploads[1].MIME)
+	assert.Equal(t, "local mp3", data[1], "read from disk of local bot api server")
+
+	assert.Equal(t, []string{"Published to podcast: Episode 12", "Published to podcast: ep13",
+		`Can't publish: unknown feed-set "broken"`, "Publishing is allowed to admins only"}, sent)
+
+	_, err = client.openFile(&tb.File{FileID: "missing"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "can't get file missing")
+	assert.NotContains(t, err.Error(), "token", "bot token hidden")
+}
+
+func TestIsAudioDocument(t *testing.T) {
+	assert.True(t, isAudioDocument(&tb.Document{MIME: "audio/x-m4a", FileName: "ep"}))
+	assert.True(t, isAudioDocument(&tb.Document{FileName: "ep.MP3"}))
+	assert.False(t, isAudioDocument(&tb.Document{MIME: "text/x-opml", FileName: "feeds.opml"}))
+	assert.False(t, isAudioDocument(nil))
+}
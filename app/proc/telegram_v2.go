@@ -1,26 +1,78 @@
 package proc
 
 import (
+	"context"
 	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf16"
 
 	"github.com/pkg/errors"
-	"golang.org/x/net/html"
 
 	log "github.com/go-pkgz/lgr"
-	"github.com/microcosm-cc/bluemonday"
 	tb "gopkg.in/tucnak/telebot.v2"
 
 	"github.com/umputun/feed-master/app/feed"
 )
 
-// TelegramClientV2 client
+// TelegramClientV2 is a telegram bot handling commands, sending to channels is done by embedded TelegramClient
 type TelegramClientV2 struct {
-	Bot *tb.Bot
+	TelegramClient
+
+	// posts of channels the bot is admin of, ignored unless both set
+	AcceptPost  func(chat *tb.Chat) bool     // checks if posts of the channel are ingested
+	DeliverPost func(post ChannelPost) error // called for each ingested post, edited ones included
+
+	Admins  []string                        // users allowed to publish audio, ids or usernames
+	Publish func(up Upload) (string, error) // called for audio sent by admin, returns name of feed-set
+}
+
+// Upload is audio sent to the bot by admin, published as item of feed-set
+type Upload struct {
+	Feed        string // feed-set chosen by #name at the start of caption, empty if not chosen
+	Title       string
+	Description string // html
+	Author      string
+	Date        time.Time
+	Name        string // file name
+	MIME        string
+	Duration    int                           // seconds, zero if unknown
+	Open        func() (io.ReadCloser, error) // downloads the file
+}
+
+// ChannelPost is a post of telegram channel with downloaded attachments
+type ChannelPost struct {
+	Chat   *tb.Chat
+	ID     int
+	Date   time.Time
+	Author string    // author's signature, if enabled in the channel
+	Text   string    // text or caption of the post
+	HTML   string    // the same text with formatting and links
+	Photo  *PostFile // the largest size of the photo
+	Audio  *PostFile // audio file or voice message
+}
+
+// PostFile is a file attached to channel post
+type PostFile struct {
+	Name     string
+	MIME     string
+	Title    string // title of audio, with performer if set
+	Duration int    // seconds, for audio
+	Data     []byte
 }
 
-// NewTelegramClient init telegram client
+// maxFileSize is the limit of files downloaded with bot api
+const maxFileSize = 20 * 1024 * 1024
+
+// NewTelegramV2Client init telegram bot client
 func NewTelegramV2Client(token, apiURL string, timeout time.Duration) (*TelegramClientV2, error) {
 	if timeout == 0 {
 		timeout = time.Second * 60
@@ -41,22 +93,11 @@ func NewTelegramV2Client(token, apiURL string, timeout time.Duration) (*Telegram
 	}
 
 	result := TelegramClientV2{
-		Bot: bot,
+		TelegramClient: TelegramClient{Bot: bot, Timeout: timeout},
 	}
 	return &result, err
 }
 
-func (client TelegramClientV2) sendText(channelID string, item feed.Item) (*tb.Message, error) {
-	message, err := client.Bot.Send(
-		recipient{chatID: channelID},
-		client.getMessageHTML(item, true),
-		tb.ModeHTML,
-		tb.NoPreview,
-	)
-
-	return message, err
-}
-
 func (client TelegramClientV2) sendTextOnly(channelID string, text string) (*tb.Message, error) {
 	message, err := client.Bot.Send(
 		recipient{chatID: channelID},
@@ -66,51 +107,6 @@ func (client TelegramClientV2) sendTextOnly(channelID string, text string) (*tb.
 	return message, err
 }
 
-// https://core.telegram.org/bots/api#html-style
-func (client TelegramClientV2) tagLinkOnlySupport(htmlText string) string {
-	p := bluemonday.NewPolicy()
-	p.AllowAttrs("href").OnElements("a")
-	return html.UnescapeString(p.Sanitize(htmlText))
-}
-
-// getMessageHTML generates HTML message from provided feed.Item
-func (client TelegramClientV2) getMessageHTML(item feed.Item, withMp3Link bool) string {
-	description := string(item.Description)
-
-	description = strings.TrimPrefix(description, "<![CDATA[")
-	description = strings.TrimSuffix(description, "]]>")
-
-	// apparently bluemonday doesn't remove escaped HTML tags
-	description = client.tagLinkOnlySupport(html.UnescapeString(description))
-	description = strings.TrimSpace(description)
-
-	messageHTML := description
-
-	title := strings.TrimSpace(item.Title)
-	if title != "" {
-		switch {
-		case item.Link == "":
-			messageHTML = fmt.Sprintf("%s\n\n", title) + messageHTML
-		case item.Link != "":
-			messageHTML = fmt.Sprintf("<a href=\"%s\">%s</a>\n\n", item.Link, title) + messageHTML
-		}
-	}
-
-	if withMp3Link {
-		messageHTML += fmt.Sprintf("\n\n%s", item.Enclosure.URL)
-	}
-
-	return messageHTML
-}
-
-type recipient struct {
-	chatID string
-}
-
-func (r recipient) Recipient() string {
-	return r.chatID
-}
-
 const (
 	commandHello  = "/hello"
 	commandHelp   = "/help"
@@ -123,11 +119,13 @@ const (
 Use commands:
 /import - for load OPML-file
 /stop - for stop send updates
+Send a page url to find its feeds
 `
 
 	msgHelp = `Use commands:
 /import - for load OPML-file
 /stop - for stop send updates
+Send a page url to find its feeds
 `
 )
 
@@ -191,33 +189,348 @@ func (client TelegramClientV2) Start() {
 	})
 
 	client.Bot.Handle(tb.OnDocument, func(m *tb.Message) {
-		log.Printf("[DEBUG] telegram message receive document: '%s', with size: '%d'", m.Document.FileName, m.Document.FileSize)
+		if !isAudioDocument(m.Document) {
+			log.Printf("[DEBUG] telegram message receive document: '%s', with size: '%d'", m.Document.FileName, m.Document.FileSize)
+			return
+		}
+		client.upload(m)
 	})
 
+	client.Bot.Handle(tb.OnAudio, client.upload)
+
 	client.Bot.Handle(tb.OnText, func(m *tb.Message) {
-		log.Printf("[DEBUG] telegram receive unknown text: \n%s", m.Text)
+		text := strings.TrimSpace(m.Text)
+		if !m.Private() || !(strings.HasPrefix(text, "http://") || strings.HasPrefix(text, "https://")) {
+			log.Printf("[DEBUG] telegram receive unknown text: \n%s", m.Text)
+			return
+		}
+		client.Bot.Send(m.Sender, client.discoverMessage(text), tb.NoPreview)
 	})
 
+	client.Bot.Handle(tb.OnChannelPost, client.channelPost)
+	client.Bot.Handle(tb.OnEditedChannelPost, client.channelPost)
+
 	log.Print("[INFO] telegram bot started")
 	go client.Bot.Start()
 }
 
+// discoverMessage makes reply with feeds found for the url
+func (client TelegramClientV2) discoverMessage(uri string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), client.Timeout)
+	defer cancel()
+	candidates, err := feed.Discover(ctx, uri, client.Fetcher)
+	if err != nil {
+		log.Printf("[DEBUG] can't discover feeds of %s, %v", uri, err)
+		return "No feeds found at " + uri
+	}
+
+	lines := []string{"Feeds found:"}
+	for _, c := range candidates {
+		if c.Title == "" {
+			lines = append(lines, c.URL)
+			continue
+		}
+		lines = append(lines, c.Title+" - "+c.URL)
+	}
+	return strings.Join(lines, "\n")
+}
+
 func logCommand(command string, chatID int64, payload string) {
 	log.Printf("[DEBUG] telegram receive command: '%s' in chat: '%d'\n%s", command, chatID, payload)
 }
 
-// Send message, skip if telegram token empty
-func (client TelegramClientV2) Send(channelID string, item feed.Item) (err error) {
-	if client.Bot == nil || channelID == "" {
-		return nil
+// channelPost passes post of ingested channel with its attachments to DeliverPost
+func (client TelegramClientV2) channelPost(m *tb.Message) {
+	if client.AcceptPost == nil || client.DeliverPost == nil || !client.AcceptPost(m.Chat) {
+		log.Printf("[DEBUG] telegram post %d of channel %q ignored", m.ID, m.Chat.Title)
+		return
+	}
+	post, err := client.makePost(m)
+	if err != nil {
+		log.Printf("[WARN] can't get telegram post %d of channel %q, %v", m.ID, m.Chat.Title, err)
+		return
+	}
+	if err = client.DeliverPost(post); err != nil {
+		log.Printf("[WARN] can't deliver telegram post %d of channel %q, %v", m.ID, m.Chat.Title, err)
+	}
+}
+
+// makePost converts channel's message to post, downloads its photo and audio
+func (client TelegramClientV2) makePost(m *tb.Message) (ChannelPost, error) {
+	post := ChannelPost{Chat: m.Chat, ID: m.ID, Date: m.Time(), Author: m.Signature}
+	text, entities := m.Text, m.Entities
+	if text == "" {
+		text, entities = m.Caption, m.CaptionEntities
+	}
+	post.Text, post.HTML = text, entitiesHTML(text, entities)
+
+	if m.Photo != nil {
+		data, err := client.download(&m.Photo.File)
+		if err != nil {
+			return ChannelPost{}, errors.Wrap(err, "can't download photo")
+		}
+		post.Photo = &PostFile{Name: fmt.Sprintf("%d-photo.jpg", m.ID), MIME: "image/jpeg", Data: data}
+	}
+
+	var audio *PostFile
+	var file *tb.File
+	switch {
+	case m.Audio != nil:
+		audio = &PostFile{Name: m.Audio.FileName, MIME: m.Audio.MIME, Title: m.Audio.Title, Duration: m.Audio.Duration}
+		if m.Audio.Performer != "" && audio.Title != "" {
+			audio.Title = m.Audio.Performer + " - " + audio.Title
+		}
+		if audio.Name == "" {
+			audio.Name = "audio.mp3"
+		}
+		if audio.MIME == "" {
+			audio.MIME = "audio/mpeg"
+		}
+		file = &m.Audio.File
+	case m.Voice != nil:
+		audio = &PostFile{Name: "voice.ogg", MIME: m.Voice.MIME, Duration: m.Voice.Duration}
+		if audio.MIME == "" {
+			audio.MIME = "audio/ogg"
+		}
+		file = &m.Voice.File
+	}
+	if audio != nil {
+		data, err := client.download(file)
+		if err != nil {
+			return ChannelPost{}, errors.Wrap(err, "can't download audio")
+		}
+		audio.Data = data
+		audio.Name = fmt.Sprintf("%d-%s", m.ID, path.Base(strings.ReplaceAll(audio.Name, "\\", "/")))
+		post.Audio = audio
+	}
+	return post, nil
+}
+
+// download gets file with bot api, up to 20M
+func (client TelegramClientV2) download(f *tb.File) ([]byte, error) {
+	if f.FileSize > maxFileSize {
+		return nil, errors.Errorf("file of %d bytes is too big", f.FileSize)
+	}
+	rd, err := client.openFile(f)
+	if err != nil {
+		return nil, err
+	}
+	defer rd.Close() // nolint
+	data, err := ioutil.ReadAll(io.LimitReader(rd, maxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxFileSize {
+		return nil, errors.New("file is too big")
+	}
+	return data, nil
+}
+
+// entitiesHTML converts text with telegram entities to html, line breaks to <br>.
+// Entities crossing bounds of outer ones are cut at them.
+func entitiesHTML(text string, entities []tb.MessageEntity) string {
+	units := utf16.Encode([]rune(text))
+	ents := make([]tb.MessageEntity, len(entities))
+	copy(ents, entities)
+	sort.SliceStable(ents, func(i, j int) bool {
+		if ents[i].Offset != ents[j].Offset {
+			return ents[i].Offset < ents[j].Offset
+		}
+		return ents[i].Length > ents[j].Length
+	})
+
+	type openTag struct {
+		close string
+		end   int
 	}
+	var buf strings.Builder
+	stack := []openTag{}
+	pos := 0
+	write := func(end int) {
+		s := html.EscapeString(string(utf16.Decode(units[pos:end])))
+		buf.WriteString(strings.ReplaceAll(s, "\n", "<br>"))
+		pos = end
+	}
+	closeTo := func(p int) {
+		for len(stack) > 0 && stack[len(stack)-1].end <= p {
+			top := stack[len(stack)-1]
+			write(top.end)
+			buf.WriteString(top.close)
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	for _, e := range ents {
+		start, end := e.Offset, e.Offset+e.Length
+		if end > len(units) {
+			end = len(units)
+		}
+		if start < pos || start >= end {
+			continue
+		}
+		closeTo(start)
+		if len(stack) > 0 && end > stack[len(stack)-1].end {
+			end = stack[len(stack)-1].end
+		}
+		open, close := entityTags(e, string(utf16.Decode(units[start:end])))
+		if open == "" {
+			continue
+		}
+		write(start)
+		buf.WriteString(open)
+		stack = append(stack, openTag{close: close, end: end})
+	}
+	closeTo(len(units))
+	write(len(units))
+	return buf.String()
+}
 
-	message, err := client.sendText(channelID, item)
+// entityTags returns opening and closing html tags of the entity, empty for entities kept as text
+func entityTags(e tb.MessageEntity, content string) (open, close string) {
+	link := func(href string) (string, string) {
+		return `<a href="` + html.EscapeString(href) + `">`, "</a>"
+	}
+	switch e.Type {
+	case tb.EntityBold:
+		return "<b>", "</b>"
+	case tb.EntityItalic:
+		return "<i>", "</i>"
+	case tb.EntityUnderline:
+		return "<u>", "</u>"
+	case tb.EntityStrikethrough:
+		return "<s>", "</s>"
+	case tb.EntityCode:
+		return "<code>", "</code>"
+	case tb.EntityCodeBlock:
+		return "<pre>", "</pre>"
+	case tb.EntityTextLink:
+		return link(e.URL)
+	case tb.EntityURL:
+		if !strings.Contains(content, "://") {
+			content = "http://" + content
+		}
+		return link(content)
+	case tb.EntityEmail:
+		return link("mailto:" + content)
+	case tb.EntityMention:
+		return link("https://t.me/" + strings.TrimPrefix(content, "@"))
+	}
+	return "", ""
+}
 
+// upload publishes audio sent by admin in private chat, replies with the result
+func (client TelegramClientV2) upload(m *tb.Message) {
+	if !m.Private() || client.Publish == nil {
+		return
+	}
+	if !client.isAdmin(m.Sender) {
+		log.Printf("[WARN] telegram upload from not allowed user %d (%s)", m.Sender.ID, m.Sender.Username)
+		client.Bot.Send(m.Sender, "Publishing is allowed to admins only") // nolint
+		return
+	}
+
+	up := Upload{Date: m.Time(), Author: strings.TrimSpace(m.Sender.FirstName + " " + m.Sender.LastName)}
+	var file *tb.File
+	switch {
+	case m.Audio != nil:
+		file = &m.Audio.File
+		up.Name, up.MIME, up.Duration, up.Title = m.Audio.FileName, m.Audio.MIME, m.Audio.Duration, m.Audio.Title
+		if up.Name == "" {
+			up.Name = "audio.mp3"
+		}
+	case m.Document != nil:
+		file = &m.Document.File
+		up.Name, up.MIME = m.Document.FileName, m.Document.MIME
+	default:
+		return
+	}
+	up.Name = path.Base(strings.ReplaceAll(up.Name, "\\", "/"))
+	if up.MIME == "" {
+		up.MIME = "audio/mpeg"
+	}
+	up.Open = func() (io.ReadCloser, error) { return client.openFile(file) }
+
+	up.Feed, up.Title, up.Description = uploadCaption(m.Caption, m.CaptionEntities, up.Title)
+	if up.Title == "" {
+		up.Title = strings.TrimSuffix(up.Name, path.Ext(up.Name))
+	}
+
+	name, err := client.Publish(up)
 	if err != nil {
-		return errors.Wrapf(err, "can't send to telegram for %+v", item.Enclosure)
+		log.Printf("[WARN] can't publish %s from %s, %v", up.Name, m.Sender.Username, err)
+		client.Bot.Send(m.Sender, "Can't publish: "+err.Error()) // nolint
+		return
+	}
+	client.Bot.Send(m.Sender, fmt.Sprintf("Published to %s: %s", name, up.Title)) // nolint
+}
+
+// isAdmin checks if user is allowed to publish, by id or username
+func (client TelegramClientV2) isAdmin(u *tb.User) bool {
+	if u == nil {
+		return false
+	}
+	for _, a := range client.Admins {
+		a = strings.TrimPrefix(strings.TrimSpace(a), "@")
+		if a == strconv.FormatInt(u.ID, 10) || (u.Username != "" && strings.EqualFold(a, u.Username)) {
+			return true
+		}
 	}
+	return false
+}
+
+// isAudioDocument checks if document is audio, by its mime type or extension
+func isAudioDocument(d *tb.Document) bool {
+	return d != nil && (strings.HasPrefix(d.MIME, "audio/") || feed.IsDirAudio(d.FileName))
+}
 
-	log.Printf("[DEBUG] telegram message sent: \n%s", message.Text)
-	return nil
+// uploadCaption returns feed-set chosen by #name at the start of caption, title from the first line of caption
+// and the caption with its formatting as html description. Title falls back to the given one if caption is empty.
+func uploadCaption(caption string, entities []tb.MessageEntity, title string) (name, resTitle, description string) {
+	text := strings.TrimLeftFunc(caption, unicode.IsSpace)
+	if strings.HasPrefix(text, "#") {
+		end := strings.IndexFunc(text, unicode.IsSpace)
+		if end < 0 {
+			end = len(text)
+		}
+		name, text = text[1:end], strings.TrimLeftFunc(text[end:], unicode.IsSpace)
+	}
+	// entities are relative to the whole caption, shifted to the rest of it
+	shift := len(utf16.Encode([]rune(caption[:len(caption)-len(text)])))
+	if text = strings.TrimRightFunc(text, unicode.IsSpace); text == "" {
+		return name, title, ""
+	}
+	ents := []tb.MessageEntity{}
+	for _, e := range entities {
+		e.Offset -= shift
+		if e.Offset >= 0 {
+			ents = append(ents, e)
+		}
+	}
+
+	resTitle = strings.TrimSpace(strings.SplitN(text, "\n", 2)[0])
+	return name, resTitle, entitiesHTML(text, ents)
+}
+
+// openFile opens file sent to the bot. Local bot api server returns path of the file on its disk,
+// it's read from there, so the server's directory has to be shared with feed-master.
+// Remote file streamed by the client's fetcher, limited by the client's timeout.
+func (client TelegramClientV2) openFile(f *tb.File) (io.ReadCloser, error) {
+	fileURL, err := client.Bot.FileURLByID(f.FileID)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(fileURL, "file://") {
+		return os.Open(strings.TrimPrefix(fileURL, "file://")) // nolint
+	}
+
+	fetcher := client.Fetcher
+	if fetcher == nil {
+		fetcher = feed.DefaultFetcher()
+	}
+	rd, err := fetcher.Stream(context.Background(), fileURL, client.Timeout)
+	if err != nil {
+		// file url has bot token in it
+		return nil, errors.Errorf("can't get file %s, %s", f.FileID, strings.ReplaceAll(err.Error(), client.Bot.Token, "***"))
+	}
+	return rd, nil
 }
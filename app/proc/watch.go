@@ -0,0 +1,81 @@
+package proc
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"html/template"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/pkg/errors"
+
+	"github.com/umputun/feed-master/app/feed"
+	"github.com/umputun/feed-master/app/models"
+)
+
+// watch gets page of watch source and compares its text with the feed-set's snapshot kept in FeedsStore.
+// Returns an item with diff if the text changed, ErrNotModified if not, and no items for the first snapshot.
+// The new snapshot returned to be saved after the item, snapshots kept per feed-set as items are.
+func (p *Processor) watch(ctx context.Context, name string, src Source, opts feed.ParseOpts) (feed.Rss2, *models.Snapshot, error) {
+	if p.FeedsStore == nil {
+		return feed.Rss2{}, nil, errors.New("no store for snapshots")
+	}
+	page, err := feed.Watch(ctx, src.URL, src.Selector, opts)
+	if err != nil {
+		return feed.Rss2{}, nil, err
+	}
+
+	key := name + " " + src.URL
+	if src.Selector != "" {
+		key += " " + src.Selector
+	}
+	prev, found, err := p.FeedsStore.LoadSnapshot(key)
+	if err != nil {
+		return feed.Rss2{}, nil, errors.Wrapf(err, "can't load snapshot of %s", key)
+	}
+	hash := page.Hash()
+	if found && prev.Hash == hash {
+		return feed.Rss2{}, nil, feed.ErrNotModified
+	}
+
+	now := time.Now()
+	snapshot := &models.Snapshot{Key: key, Hash: hash, Text: page.Text, Updated: now}
+	result := feed.Rss2{Version: "2.0", Title: page.Title, Link: src.URL, FeedURL: src.URL}
+	if !found {
+		log.Printf("[INFO] first snapshot of %s, source %q", key, src.Name)
+		return result, snapshot, nil
+	}
+	result.ItemList = []feed.Item{watchItem(src, page, prev.Text, now)}
+	result, err = result.Normalize()
+	return result, snapshot, err
+}
+
+// saveSnapshot stores snapshot of watched page, nil snapshot ignored
+func (p *Processor) saveSnapshot(snapshot *models.Snapshot) {
+	if snapshot == nil || p.FeedsStore == nil {
+		return
+	}
+	if err := p.FeedsStore.SaveSnapshot(*snapshot); err != nil {
+		log.Printf("[WARN] failed to save snapshot of %s, %v", snapshot.Key, err)
+	}
+}
+
+// watchItem makes item of the page change, with diff of texts as description
+func watchItem(src Source, page feed.Page, prevText string, dt time.Time) feed.Item {
+	title := page.Title
+	if title == "" {
+		title = src.Name
+	}
+	if title == "" {
+		title = src.URL
+	}
+	diff := feed.TextDiff(prevText, page.Text)
+	return feed.Item{
+		Title:       title + " changed",
+		Link:        src.URL,
+		GUID:        fmt.Sprintf("%s#%d-%s", src.URL, dt.Unix(), page.Hash()[:8]),
+		PubDate:     dt.Format(time.RFC1123Z),
+		Description: template.HTML("<pre>" + html.EscapeString(diff) + "</pre>"), // nolint
+	}
+}
@@ -32,6 +32,23 @@ func (b BoldStore) Iterate(cb func(feed models.Feed) error) error {
 	return err
 }
 
+// Load returns stored feed by feed-set and url, found false if not stored yet
+func (b BoldStore) Load(feedSet, url string) (feed models.Feed, found bool, err error) {
+	err = b.DB.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(bucketNameFeed))
+		if bucket == nil {
+			return nil
+		}
+		data := bucket.Get(b.keyFeed(models.Feed{FeedSet: feedSet, URL: url}))
+		if data == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(data, &feed)
+	})
+	return feed, found, err
+}
+
 func (b BoldStore) Save(feed models.Feed) (bool, error) {
 	var created bool
 
@@ -41,10 +58,7 @@ func (b BoldStore) Save(feed models.Feed) (bool, error) {
 			return e
 		}
 
-		key, e := b.keyFeed(feed)
-		if e != nil {
-			return e
-		}
+		key := b.keyFeed(feed)
 
 		data, e := json.Marshal(&feed)
 		if e != nil {
@@ -64,6 +78,9 @@ func (b BoldStore) Save(feed models.Feed) (bool, error) {
 	return created, err
 }
 
-func (b BoldStore) keyFeed(f models.Feed) ([]byte, error) {
-	return []byte(f.URL), nil
+func (b BoldStore) keyFeed(f models.Feed) []byte {
+	if f.FeedSet == "" {
+		return []byte(f.URL)
+	}
+	return []byte(f.FeedSet + " " + f.URL)
 }
@@ -0,0 +1,43 @@
+package store
+
+import (
+	"encoding/json"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/umputun/feed-master/app/models"
+)
+
+const bucketNameSnapshot = "Snapshots"
+
+// LoadSnapshot returns stored snapshot by key, found false if not stored yet
+func (b BoldStore) LoadSnapshot(key string) (snap models.Snapshot, found bool, err error) {
+	err = b.DB.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(bucketNameSnapshot))
+		if bucket == nil {
+			return nil
+		}
+		data := bucket.Get([]byte(key))
+		if data == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(data, &snap)
+	})
+	return snap, found, err
+}
+
+// SaveSnapshot stores snapshot, replaces the previous one with the same key
+func (b BoldStore) SaveSnapshot(snap models.Snapshot) error {
+	return b.DB.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(bucketNameSnapshot))
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(&snap)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(snap.Key), data)
+	})
+}